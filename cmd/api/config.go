@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/IavilaGw/cat-api/internal/config"
+)
+
+const redacted = "<redacted>"
+
+// runConfig prints the effective configuration. Invalid settings are listed
+// after the table and make the command exit non-zero, so it doubles as a
+// check before deploying.
+func runConfig(args []string) {
+	if len(args) == 0 || args[0] != "print" {
+		log.Fatalf("Usage: config print [-show-secrets]")
+	}
+
+	fs := flag.NewFlagSet("config print", flag.ExitOnError)
+	showSecrets := fs.Bool("show-secrets", false, "print secret values instead of redacting them")
+	fs.Parse(args[1:])
+
+	cfg, err := config.LoadConfig()
+	if cfg == nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tSOURCE\tVALUE")
+	for _, setting := range cfg.Settings() {
+		value := setting.Value
+		if setting.Secret && value != "" && !*showSecrets {
+			value = redacted
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", setting.Key, setting.Source, value)
+	}
+	w.Flush()
+
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+}
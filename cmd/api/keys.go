@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/IavilaGw/cat-api/internal/config"
+	"github.com/IavilaGw/cat-api/internal/database"
+	"github.com/IavilaGw/cat-api/internal/logging"
+	"github.com/IavilaGw/cat-api/internal/repositories"
+	"github.com/IavilaGw/cat-api/internal/services"
+)
+
+func runKeys(args []string) {
+	if len(args) == 0 {
+		log.Fatalf("Usage: keys create|list|revoke")
+	}
+
+	fs := flag.NewFlagSet("keys "+args[0], flag.ExitOnError)
+	name := fs.String("name", "", "name to tell the key apart (create only)")
+	scopes := fs.String("scopes", "read", "comma-separated scopes: read, fetch, write, admin (create only)")
+	expires := fs.Duration("expires", 0, "lifetime of the key, e.g. 720h; 0 never expires (create only)")
+	fs.Parse(args[1:])
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	logger, err := logging.New(&cfg.Log, os.Stderr)
+	if err != nil {
+		log.Fatalf("Failed to init logger: %v", err)
+	}
+
+	db, err := database.NewDatabase(&cfg.Database)
+	if err != nil {
+		log.Fatalf("Error database: %v", err)
+	}
+	defer db.Close()
+
+	keyService := services.NewAPIKeyService(repositories.NewAPIKeyRepository(db.DB), logger)
+	ctx := context.Background()
+
+	switch args[0] {
+	case "create":
+		if *name == "" {
+			log.Fatalf("Usage: keys create -name NAME [-scopes read,fetch] [-expires 720h]")
+		}
+		parsed, err := services.ParseScopes(*scopes)
+		if err != nil {
+			log.Fatalf("Invalid scopes: %v", err)
+		}
+		plaintext, key, err := keyService.CreateKey(ctx, *name, parsed, *expires)
+		if err != nil {
+			log.Fatalf("Failed to create key: %v", err)
+		}
+		log.Printf("Created key %d (%s) with scopes %s; store it now, it cannot be shown again", key.ID, key.Name, key.Scopes)
+		fmt.Fprintln(os.Stdout, plaintext)
+	case "list":
+		keys, err := keyService.ListKeys(ctx)
+		if err != nil {
+			log.Fatalf("Failed to list keys: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tPREFIX\tSCOPES\tEXPIRES\tLAST USED\tSTATUS")
+		now := time.Now()
+		for _, key := range keys {
+			status := "active"
+			switch {
+			case key.RevokedAt != nil:
+				status = "revoked"
+			case !key.Usable(now):
+				status = "expired"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				key.ID, key.Name, key.Prefix, key.Scopes, formatKeyTime(key.ExpiresAt), formatKeyTime(key.LastUsedAt), status)
+		}
+		w.Flush()
+	case "revoke":
+		if fs.NArg() != 1 {
+			log.Fatalf("Usage: keys revoke ID")
+		}
+		id, err := strconv.ParseUint(fs.Arg(0), 10, 64)
+		if err != nil {
+			log.Fatalf("Invalid key id %q", fs.Arg(0))
+		}
+		err = keyService.RevokeKey(ctx, uint(id))
+		if errors.Is(err, services.ErrAPIKeyNotFound) {
+			log.Fatalf("Key %d not found or already revoked", id)
+		}
+		if err != nil {
+			log.Fatalf("Failed to revoke key: %v", err)
+		}
+		log.Printf("Revoked key %d", id)
+	default:
+		log.Fatalf("Unknown keys command %q (available: create, list, revoke)", args[0])
+	}
+}
+
+func formatKeyTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format("2006-01-02 15:04")
+}
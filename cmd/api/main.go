@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,53 +14,220 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/IavilaGw/cat-api/internal/breaker"
 	"github.com/IavilaGw/cat-api/internal/config"
 	"github.com/IavilaGw/cat-api/internal/database"
 	"github.com/IavilaGw/cat-api/internal/handlers"
+	"github.com/IavilaGw/cat-api/internal/janitor"
+	"github.com/IavilaGw/cat-api/internal/logging"
+	"github.com/IavilaGw/cat-api/internal/metrics"
+	"github.com/IavilaGw/cat-api/internal/middleware"
+	"github.com/IavilaGw/cat-api/internal/models"
+	"github.com/IavilaGw/cat-api/internal/ratelimit"
 	"github.com/IavilaGw/cat-api/internal/repositories"
 	"github.com/IavilaGw/cat-api/internal/services"
+	"github.com/IavilaGw/cat-api/internal/storage"
+	"github.com/IavilaGw/cat-api/internal/telemetry"
+	"github.com/IavilaGw/cat-api/internal/tlsconfig"
 	"github.com/IavilaGw/cat-api/pkg/client"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+		case "migrate":
+			runMigrate(os.Args[2:])
+			return
+		case "migrate-blobs":
+			runMigrateBlobs(os.Args[2:])
+			return
+		case "keys":
+			runKeys(os.Args[2:])
+			return
+		case "config":
+			runConfig(os.Args[2:])
+			return
+		default:
+			log.Fatalf("Unknown command %q (available: serve, migrate, migrate-blobs, keys, config)", os.Args[1])
+		}
+	}
 
+	runServer()
+}
+
+func runServer() {
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	logger, err := logging.New(&cfg.Log, os.Stdout)
+	if err != nil {
+		log.Fatalf("Failed to init logger: %v", err)
+	}
+	// Route the standard log package (and anything still using it) through
+	// the structured logger.
+	slog.SetDefault(logger)
+
 	gin.SetMode(cfg.Server.Mode)
 
+	shutdownTracing, err := telemetry.Setup(context.Background(), &cfg.App.Tracing)
+	if err != nil {
+		log.Fatalf("Failed to init tracing: %v", err)
+	}
+
 	db, err := database.NewDatabase(&cfg.Database)
 	if err != nil {
 		log.Fatalf("Error database: %v", err)
 	}
 	defer db.Close()
 
-	if err := db.AutoMigrate(); err != nil {
+	if err := telemetry.InstrumentGORM(db.DB); err != nil {
+		log.Fatalf("Failed to instrument database: %v", err)
+	}
+
+	if err := checkSchema(db, cfg.Database.AutoMigrate); err != nil {
 		log.Fatalf("Failed to migrate: %v", err)
 	}
 
-	cataasClient := client.NewCataasClient(cfg.App.CataasAPIURL, cfg.App.TimeoutSeconds)
-	catRepo := repositories.NewCatRepository(db.DB)
-	catService := services.NewCatServiceWithConcrete(catRepo, cataasClient)
+	blobStore, err := storage.NewBlobStore(&cfg.App.BlobStore)
+	if err != nil {
+		log.Fatalf("Failed to init blob store: %v", err)
+	}
+
+	cataasClient := client.NewCataasClient(cfg.App.CataasAPIURL, cfg.App.TimeoutSeconds).
+		WithRetryPolicy(client.RetryPolicy{
+			MaxAttempts: cfg.App.Retry.MaxAttempts,
+			BaseBackoff: cfg.App.Retry.BaseBackoff,
+			MaxBackoff:  cfg.App.Retry.MaxBackoff,
+			Jitter:      cfg.App.Retry.Jitter,
+		}).
+		WithLogger(logger)
+	circuit := breaker.New(breaker.Config{
+		FailureRate:    cfg.App.Breaker.FailureRate,
+		MinRequests:    cfg.App.Breaker.MinRequests,
+		Window:         cfg.App.Breaker.Window,
+		CoolDown:       cfg.App.Breaker.CoolDown,
+		HalfOpenProbes: cfg.App.Breaker.HalfOpenProbes,
+	})
+
+	appMetrics := metrics.New()
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		log.Fatalf("Error database: %v", err)
+	}
+	appMetrics.RegisterDBStats(sqlDB, cfg.Database.DBName)
+
+	deletedPolicy, err := repositories.ParseDeletedImagePolicy(cfg.App.DeletedImagePolicy)
+	if err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	catRepo := repositories.NewCatRepository(db.DB, blobStore).WithDeletedImagePolicy(deletedPolicy)
+	appMetrics.RegisterImageStats(catRepo.GetStats)
+
+	janitorLock, err := database.NewAdvisoryLock(db, janitorLockID)
+	if err != nil {
+		log.Fatalf("Error database: %v", err)
+	}
+	retention, err := newJanitor(&cfg.App.Retention, catRepo, janitorLock, appMetrics, logger)
+	if err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	if retention != nil {
+		retention.Start()
+	}
+
+	upstream := appMetrics.InstrumentClient(services.NewCataasClientAdapter(cataasClient))
+	catService := services.NewCatService(appMetrics.InstrumentRepository(catRepo),
+		services.NewCircuitBreakerClient(upstream, circuit), logger).
+		WithUploadLimits(services.UploadLimits{
+			MaxBytes:  cfg.App.Upload.MaxBytes,
+			MaxPixels: cfg.App.Upload.MaxPixels,
+		})
+
+	catHandler := handlers.NewCatHandler(catService, logger)
+	healthHandler := handlers.NewHealthHandler(db, cataasClient, circuit)
+	janitorHandler := handlers.NewJanitorHandler(retention)
+
+	if !cfg.Auth.Enabled {
+		logger.Warn("api key authentication is disabled; every route is public")
+	}
+	auth := middleware.NewAPIKeyAuth(services.NewAPIKeyService(repositories.NewAPIKeyRepository(db.DB), logger), logger).
+		WithDisabled(!cfg.Auth.Enabled).
+		WithPublicRead(cfg.Auth.PublicRead)
+
+	limiter, err := newRateLimiter(&cfg.RateLimit, db, logger)
+	if err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+
+	cors, err := middleware.NewCORS(middleware.CORSPolicy{
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowedHeaders:   cfg.CORS.AllowedHeaders,
+		ExposedHeaders:   cfg.CORS.ExposedHeaders,
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           cfg.CORS.MaxAge,
+	})
+	if err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+
+	var securityHeaders gin.HandlerFunc
+	switch cfg.Server.SecurityHeaders {
+	case "auto":
+		if cfg.Server.TLS.Enabled() {
+			securityHeaders = middleware.SecurityHeaders(cfg.Server.HSTSMaxAge)
+		}
+	case "on":
+		securityHeaders = middleware.SecurityHeaders(cfg.Server.HSTSMaxAge)
+	case "off":
+	default:
+		log.Fatalf("Invalid config: unknown SECURITY_HEADERS %q (want auto, on or off)", cfg.Server.SecurityHeaders)
+	}
 
-	catHandler := handlers.NewCatHandler(catService)
-	healthHandler := handlers.NewHealthHandler(db, cataasClient)
+	router := setupRouter(catHandler, healthHandler, janitorHandler, auth, limiter, cors, securityHeaders, appMetrics, logger, cfg.App.Tracing.ServiceName)
+	// Without trusted proxies gin would believe any X-Forwarded-For, and
+	// clients could dodge per-IP rate limits by making one up.
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid config: TRUSTED_PROXIES: %v", err)
+	}
 
-	router := setupRouter(catHandler, healthHandler)
+	// Request contexts derive from baseCtx so a stalled shutdown can cancel
+	// in-flight upstream fetches and database writes.
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
 
 	addr := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
 	srv := &http.Server{
 		Addr:           addr,
 		Handler:        router,
+		BaseContext:    func(net.Listener) context.Context { return baseCtx },
 		ReadTimeout:    10 * time.Second,
 		WriteTimeout:   10 * time.Second,
 		MaxHeaderBytes: 1 << 20,
 	}
 
+	var certReloader *tlsconfig.Reloader
+	if cfg.Server.TLS.Enabled() {
+		certReloader, srv.TLSConfig, err = newTLS(&cfg.Server.TLS, logger)
+		if err != nil {
+			log.Fatalf("Invalid config: %v", err)
+		}
+		certReloader.Start(cfg.Server.TLS.ReloadInterval)
+	}
+
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Info("server listening", "addr", addr, "tls", srv.TLSConfig != nil)
+		var err error
+		if srv.TLSConfig != nil {
+			// The certificate comes from TLSConfig.GetCertificate.
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("error: %v", err)
 		}
 	}()
@@ -65,32 +235,158 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-
+	logger.Info("shutting down")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
+		cancelRequests()
 		log.Fatalf("Shutdown error: %v", err)
 	}
 
+	// Stop the janitor before the deferred db.Close so no purge is cut off
+	// halfway between the row and the blob.
+	if retention != nil {
+		retention.Stop()
+	}
+	if certReloader != nil {
+		certReloader.Stop()
+	}
+
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("failed to flush traces", "error", err)
+	}
+
+}
+
+// janitorLockID is the pg_advisory_lock key that lets only one replica at a
+// time run the janitor.
+const janitorLockID int64 = 7_310_004_117
+
+// newJanitor returns nil when no retention limit is configured.
+func newJanitor(cfg *config.RetentionConfig, store janitor.Store, locker janitor.Locker, appMetrics *metrics.Metrics, logger *slog.Logger) (*janitor.Janitor, error) {
+	policy, err := janitor.ParsePolicy(cfg.Policy)
+	if err != nil {
+		return nil, err
+	}
+
+	janitorCfg := janitor.Config{
+		Interval:  cfg.Interval,
+		MaxBytes:  cfg.MaxBytes,
+		MaxImages: cfg.MaxImages,
+		MaxAge:    cfg.MaxAge,
+		Policy:    policy,
+		DryRun:    cfg.DryRun,
+	}
+	if !janitorCfg.Enabled() {
+		return nil, nil
+	}
+	if janitorCfg.Interval <= 0 {
+		return nil, fmt.Errorf("RETENTION_INTERVAL must be positive, got %s", janitorCfg.Interval)
+	}
+
+	return janitor.New(store, janitorCfg, logger.With("component", "janitor")).
+		WithLocker(locker).
+		WithReporter(appMetrics.ObserveJanitorRun), nil
 }
 
-func setupRouter(catHandler *handlers.CatHandler, healthHandler *handlers.HealthHandler) *gin.Engine {
+func newTLS(cfg *config.TLSConfig, logger *slog.Logger) (*tlsconfig.Reloader, *tls.Config, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, nil, fmt.Errorf("TLS needs both TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+	if cfg.ReloadInterval <= 0 {
+		return nil, nil, fmt.Errorf("TLS_RELOAD_INTERVAL must be positive, got %s", cfg.ReloadInterval)
+	}
+
+	reloader, err := tlsconfig.NewReloader(cfg.CertFile, cfg.KeyFile, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	tlsCfg, err := tlsconfig.New(tlsconfig.Options{
+		MinVersion:   cfg.MinVersion,
+		ClientCAFile: cfg.ClientCAFile,
+		ClientAuth:   cfg.ClientAuth,
+	}, reloader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return reloader, tlsCfg, nil
+}
+
+// newRateLimiter returns a limiter with no store when RATE_LIMIT_BACKEND is
+// "none".
+func newRateLimiter(cfg *config.RateLimitConfig, db *database.Database, logger *slog.Logger) (*middleware.RateLimiter, error) {
+	var store ratelimit.Store
+	switch cfg.Backend {
+	case "memory":
+		store = ratelimit.NewMemoryStore()
+	case "postgres":
+		store = ratelimit.NewPostgresStore(db.DB)
+	case "none":
+	default:
+		return nil, fmt.Errorf("unknown RATE_LIMIT_BACKEND %q (want memory, postgres or none)", cfg.Backend)
+	}
+
+	limiter := middleware.NewRateLimiter(store, logger)
+	for group, rule := range map[string]config.RateLimitRule{
+		"read":  cfg.Read,
+		"fetch": cfg.Fetch,
+		"write": cfg.Write,
+		"admin": cfg.Admin,
+		"auth":  cfg.Auth,
+	} {
+		limit, err := ratelimit.ParseLimit(rule.Rate, rule.Burst)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit for %s: %w", group, err)
+		}
+		limiter.WithLimit(group, limit)
+	}
+	return limiter, nil
+}
+
+func setupRouter(catHandler *handlers.CatHandler, healthHandler *handlers.HealthHandler, janitorHandler *handlers.JanitorHandler, auth *middleware.APIKeyAuth, limiter *middleware.RateLimiter, cors *middleware.CORS, securityHeaders gin.HandlerFunc, appMetrics *metrics.Metrics, logger *slog.Logger, serviceName string) *gin.Engine {
 	router := gin.New()
-	router.Use(gin.Logger())
+	router.Use(otelgin.Middleware(serviceName))
+	router.Use(middleware.RequestID())
+	router.Use(middleware.AccessLog(logger))
+	router.Use(appMetrics.Middleware())
 	router.Use(gin.Recovery())
-	router.Use(corsMiddleware())
+	if securityHeaders != nil {
+		router.Use(securityHeaders)
+	}
+	router.Use(cors.Handler(router))
 
 	router.GET("/health", healthHandler.Health)
 	router.GET("/ready", healthHandler.Ready)
+	// Every scrape runs an aggregate over cat_images, so /metrics is an admin
+	// route with its own rate limit like /janitor.
+	router.GET("/metrics", limiter.LimitKeyLookups("auth"), auth.Require(models.ScopeAdmin), limiter.Limit("admin"), gin.WrapH(appMetrics.Handler()))
+	router.GET("/janitor", limiter.LimitKeyLookups("auth"), auth.Require(models.ScopeAdmin), limiter.Limit("admin"), janitorHandler.LastRun)
 
-	api := router.Group("/api")
+	// Each group checks its scope first, so the rate limit can key on the
+	// API key, and has its own budget. The per-IP "auth" budget in front
+	// bounds the key lookups that requests with invalid keys cause.
+	api := router.Group("/api", limiter.LimitKeyLookups("auth"))
 	{
-		api.GET("/cat", catHandler.GetRandomCat)
-		api.GET("/count", catHandler.GetCount)
-		api.GET("/stats", catHandler.GetStats)
-		api.GET("/image/:id", catHandler.GetImageByID)
+		fetch := api.Group("", auth.Require(models.ScopeFetch), limiter.Limit("fetch"))
+		fetch.GET("/cat", catHandler.GetRandomCat)
+
+		read := api.Group("", auth.Require(models.ScopeRead), limiter.Limit("read"))
+		read.GET("/count", catHandler.GetCount)
+		read.GET("/stats", catHandler.GetStats)
+		read.GET("/images", catHandler.ListImages)
+		read.GET("/image/:id", catHandler.GetImageByID)
+		read.HEAD("/image/:id", catHandler.GetImageByID)
+		read.GET("/image/:id/meta", catHandler.GetImageMeta)
+		read.GET("/image/hash/:sha256", catHandler.GetImageByHash)
+		read.HEAD("/image/hash/:sha256", catHandler.GetImageByHash)
+
+		write := api.Group("", auth.Require(models.ScopeWrite), limiter.Limit("write"))
+		write.POST("/images", catHandler.UploadImage)
+		// Soft deletes can be undone; purging cannot, so it needs admin.
+		write.DELETE("/image/:id", auth.RequireIf(models.ScopeAdmin, handlers.PurgeRequested), catHandler.DeleteImage)
+		write.POST("/image/:id/restore", catHandler.RestoreImage)
 	}
 
 	router.GET("/", func(c *gin.Context) {
@@ -98,27 +394,14 @@ func setupRouter(catHandler *handlers.CatHandler, healthHandler *handlers.Health
 			"service": "cat-api",
 			"version": "1.0.0",
 			"endpoints": gin.H{
-				"cat":   "/api/cat",
-				"count": "/api/count",
-				"stats": "/api/stats",
+				"cat":     "/api/cat",
+				"count":   "/api/count",
+				"stats":   "/api/stats",
+				"images":  "/api/images",
+				"metrics": "/metrics",
 			},
 		})
 	})
 
 	return router
 }
-
-func corsMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-
-		c.Next()
-	}
-}
\ No newline at end of file
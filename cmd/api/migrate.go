@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/IavilaGw/cat-api/internal/config"
+	"github.com/IavilaGw/cat-api/internal/database"
+)
+
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		log.Fatalf("Usage: migrate up|down|status")
+	}
+
+	fs := flag.NewFlagSet("migrate "+args[0], flag.ExitOnError)
+	steps := fs.Int("steps", 1, "number of migrations to roll back (down only)")
+	fs.Parse(args[1:])
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	db, err := database.NewDatabase(&cfg.Database)
+	if err != nil {
+		log.Fatalf("Error database: %v", err)
+	}
+	defer db.Close()
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, mig := range applied {
+			log.Printf("Applied %04d_%s", mig.Version, mig.Name)
+		}
+		if err != nil {
+			log.Fatalf("Migrate up failed: %v", err)
+		}
+		log.Printf("Schema up to date (%d applied)", len(applied))
+	case "down":
+		reverted, err := migrator.Down(ctx, *steps)
+		for _, mig := range reverted {
+			log.Printf("Reverted %04d_%s", mig.Version, mig.Name)
+		}
+		if err != nil {
+			log.Fatalf("Migrate down failed: %v", err)
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("Migrate status failed: %v", err)
+		}
+		for _, st := range statuses {
+			applied := "pending"
+			if st.Applied {
+				applied = "applied " + st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(os.Stdout, "%04d  %-30s %s\n", st.Version, st.Name, applied)
+		}
+	default:
+		log.Fatalf("Unknown migrate command %q (available: up, down, status)", args[0])
+	}
+}
+
+// checkSchema refuses to run against an outdated schema unless the operator
+// opted into applying migrations at startup.
+func checkSchema(db *database.Database, autoMigrate bool) error {
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if autoMigrate {
+		applied, err := migrator.Up(ctx)
+		for _, mig := range applied {
+			log.Printf("Applied migration %04d_%s", mig.Version, mig.Name)
+		}
+		return err
+	}
+
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		return fmt.Errorf("database schema is %d migration(s) behind; run `migrate up` or set AUTO_MIGRATE=true", pending)
+	}
+	return nil
+}
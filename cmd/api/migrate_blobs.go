@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/IavilaGw/cat-api/internal/config"
+	"github.com/IavilaGw/cat-api/internal/database"
+	"github.com/IavilaGw/cat-api/internal/repositories"
+	"github.com/IavilaGw/cat-api/internal/storage"
+)
+
+func runMigrateBlobs(args []string) {
+	fs := flag.NewFlagSet("migrate-blobs", flag.ExitOnError)
+	batchSize := fs.Int("batch-size", 100, "rows copied per batch")
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	db, err := database.NewDatabase(&cfg.Database)
+	if err != nil {
+		log.Fatalf("Error database: %v", err)
+	}
+	defer db.Close()
+
+	// The copy relies on the storage_key column, so the schema must be
+	// current just like for serve.
+	if err := checkSchema(db, cfg.Database.AutoMigrate); err != nil {
+		log.Fatalf("Failed to migrate: %v", err)
+	}
+
+	blobStore, err := storage.NewBlobStore(&cfg.App.BlobStore)
+	if err != nil {
+		log.Fatalf("Failed to init blob store: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	migrator := repositories.NewBlobMigrator(db.DB, blobStore, *batchSize)
+	progress, err := migrator.Run(ctx, func(p repositories.BlobMigrationProgress) {
+		for _, err := range p.Errors {
+			log.Printf("Skipped: %v", err)
+		}
+		log.Printf("Migrated %d/%d images (%d failed, last id %d)", p.Migrated, p.Total, p.Failed, p.LastID)
+	})
+
+	if errors.Is(err, context.Canceled) {
+		log.Printf("Interrupted after %d/%d images, run again to resume", progress.Migrated, progress.Total)
+		os.Exit(130)
+	}
+	if err != nil {
+		log.Fatalf("Blob migration failed: %v", err)
+	}
+
+	log.Printf("Blob migration done: %d migrated, %d failed", progress.Migrated, progress.Failed)
+	if progress.Failed > 0 {
+		os.Exit(1)
+	}
+}
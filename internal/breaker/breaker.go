@@ -0,0 +1,175 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrOpen = errors.New("circuit breaker is open")
+
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Config: the breaker opens when, within Window, at least MinRequests calls
+// were made and the share of failures reaches FailureRate. After CoolDown it
+// lets HalfOpenProbes calls through; if they all succeed it closes again.
+type Config struct {
+	FailureRate    float64
+	MinRequests    int
+	Window         time.Duration
+	CoolDown       time.Duration
+	HalfOpenProbes int
+}
+
+type Breaker struct {
+	cfg Config
+	now func() time.Time
+
+	mu          sync.Mutex
+	state       State
+	windowStart time.Time
+	successes   int
+	failures    int
+	openedAt    time.Time
+	inFlight    int
+	probeOK     int
+	// generation changes with every state transition, so outcomes of calls
+	// admitted under an earlier state are told apart and ignored.
+	generation uint64
+}
+
+// Permit is handed out by Allow and identifies the state the call was
+// admitted under.
+type Permit struct {
+	generation uint64
+}
+
+func New(cfg Config) *Breaker {
+	if cfg.HalfOpenProbes <= 0 {
+		cfg.HalfOpenProbes = 1
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = 1
+	}
+	return &Breaker{cfg: cfg, now: time.Now}
+}
+
+// Allow reports whether a call may proceed. Every successful Allow must be
+// followed by Record or Release with the returned permit.
+func (b *Breaker) Allow() (Permit, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	switch b.state {
+	case Open:
+		if now.Sub(b.openedAt) < b.cfg.CoolDown {
+			return Permit{}, ErrOpen
+		}
+		b.state = HalfOpen
+		b.generation++
+		b.inFlight = 0
+		b.probeOK = 0
+		fallthrough
+	case HalfOpen:
+		if b.inFlight >= b.cfg.HalfOpenProbes {
+			return Permit{}, ErrOpen
+		}
+		b.inFlight++
+		return Permit{generation: b.generation}, nil
+	default:
+		if now.Sub(b.windowStart) >= b.cfg.Window {
+			b.windowStart = now
+			b.successes = 0
+			b.failures = 0
+		}
+		return Permit{generation: b.generation}, nil
+	}
+}
+
+// Record counts the outcome of a call. Calls admitted before the last state
+// change, such as a slow call from before the breaker opened finishing
+// during the probes, are ignored.
+func (b *Breaker) Record(p Permit, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if p.generation != b.generation {
+		return
+	}
+
+	switch b.state {
+	case HalfOpen:
+		b.inFlight--
+		if !success {
+			b.trip()
+			return
+		}
+		b.probeOK++
+		if b.probeOK >= b.cfg.HalfOpenProbes {
+			b.state = Closed
+			b.generation++
+			b.windowStart = b.now()
+			b.successes = 0
+			b.failures = 0
+		}
+	case Closed:
+		if success {
+			b.successes++
+		} else {
+			b.failures++
+		}
+		total := b.successes + b.failures
+		if total >= b.cfg.MinRequests && float64(b.failures)/float64(total) >= b.cfg.FailureRate {
+			b.trip()
+		}
+	}
+}
+
+// Release gives back a permit without recording an outcome, e.g. when the
+// caller went away before the upstream answered.
+func (b *Breaker) Release(p Permit) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == HalfOpen && p.generation == b.generation && b.inFlight > 0 {
+		b.inFlight--
+	}
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.cfg.CoolDown {
+		return HalfOpen
+	}
+	return b.state
+}
+
+func (b *Breaker) trip() {
+	b.state = Open
+	b.generation++
+	b.openedAt = b.now()
+	b.inFlight = 0
+	b.probeOK = 0
+}
@@ -1,23 +1,128 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	App      AppConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	App       AppConfig
+	Log       LogConfig
+	Auth      AuthConfig
+	RateLimit RateLimitConfig
+	CORS      CORSConfig
+
+	settings []Setting
+}
+
+type CORSConfig struct {
+	// AllowedOrigins holds exact origins, glob patterns such as
+	// "https://*.example.com", or "*".
+	AllowedOrigins   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+type RateLimitConfig struct {
+	// Backend is "memory", "postgres" (shared by all replicas) or "none".
+	Backend string
+	Read    RateLimitRule
+	Fetch   RateLimitRule
+	Write   RateLimitRule
+	Admin   RateLimitRule
+	// Auth is charged per IP for every request carrying an API key, before
+	// the key is looked up.
+	Auth RateLimitRule
+}
+
+// RateLimitRule is a token bucket such as "30/m"; Burst 0 means the same as
+// the count.
+type RateLimitRule struct {
+	Rate  string
+	Burst int
+}
+
+// ParseRate parses "<count>/<s|m|h>", e.g. "30/m". An empty spec or "0"
+// returns a zero count, which disables the limit.
+func ParseRate(spec string) (count int, per time.Duration, err error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "0" {
+		return 0, 0, nil
+	}
+
+	countStr, unit, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid rate limit %q (want e.g. 30/m)", spec)
+	}
+	count, err = strconv.Atoi(strings.TrimSpace(countStr))
+	if err != nil || count < 0 {
+		return 0, 0, fmt.Errorf("invalid rate limit %q (want e.g. 30/m)", spec)
+	}
+
+	switch strings.TrimSpace(unit) {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return 0, 0, fmt.Errorf("invalid rate limit unit in %q (want s, m or h)", spec)
+	}
+	return count, per, nil
+}
+
+type AuthConfig struct {
+	// Enabled requires an API key on every /api route and /janitor.
+	Enabled bool
+	// PublicRead leaves the routes that only need the read scope open.
+	PublicRead bool
+}
+
+type LogConfig struct {
+	Level  string
+	Format string
 }
 
 type ServerConfig struct {
 	Port string
 	Host string
 	Mode string
+	// TrustedProxies lists the proxy IPs or CIDRs whose X-Forwarded-For is
+	// believed when working out the client IP. Empty trusts none.
+	TrustedProxies []string
+	TLS            TLSConfig
+	// SecurityHeaders is "auto" (on when TLS is enabled), "on" or "off".
+	SecurityHeaders string
+	HSTSMaxAge      time.Duration
+}
+
+// TLSConfig turns on HTTPS when CertFile and KeyFile are set.
+type TLSConfig struct {
+	CertFile   string
+	KeyFile    string
+	MinVersion string
+	// ClientCAFile enables mTLS; ClientAuth is "require" (default) or
+	// "optional".
+	ClientCAFile string
+	ClientAuth   string
+	// ReloadInterval is how often the certificate files are checked for
+	// changes.
+	ReloadInterval time.Duration
+}
+
+func (c *TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
 }
 
 type DatabaseConfig struct {
@@ -27,42 +132,223 @@ type DatabaseConfig struct {
 	Password string
 	DBName   string
 	SSLMode  string
+	// AutoMigrate applies pending schema migrations on startup instead of
+	// refusing to start.
+	AutoMigrate bool
 }
 
 type AppConfig struct {
 	CataasAPIURL   string
 	TimeoutSeconds int
+	Retry          RetryConfig
+	Breaker        BreakerConfig
+	BlobStore      BlobStoreConfig
+	Tracing        TracingConfig
+	Upload         UploadConfig
+	// DeletedImagePolicy is "hide" or "restore"; see
+	// repositories.DeletedImagePolicy.
+	DeletedImagePolicy string
+	Retention          RetentionConfig
 }
 
+// RetentionConfig drives the background janitor. Zero limits are not
+// enforced; with no limit at all the janitor does not run.
+type RetentionConfig struct {
+	Interval  time.Duration
+	MaxBytes  int64
+	MaxImages int64
+	// MaxAge is measured from the last access, not from creation.
+	MaxAge time.Duration
+	// Policy is "lru" or "lfu".
+	Policy string
+	DryRun bool
+}
+
+type UploadConfig struct {
+	// MaxBytes caps the size of an image sent to POST /api/images.
+	MaxBytes int64
+	// MaxPixels caps width*height so a small file cannot decode into a
+	// huge bitmap.
+	MaxPixels int
+}
+
+type TracingConfig struct {
+	// Exporter is one of "none", "otlp" or "stdout".
+	Exporter     string
+	OTLPEndpoint string
+	SampleRatio  float64
+	ServiceName  string
+}
+
+type BreakerConfig struct {
+	FailureRate    float64
+	MinRequests    int
+	Window         time.Duration
+	CoolDown       time.Duration
+	HalfOpenProbes int
+}
+
+type RetryConfig struct {
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	Jitter      float64
+}
+
+type BlobStoreConfig struct {
+	Backend     string
+	LocalPath   string
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3UseSSL    bool
+}
+
+// LoadConfig reads .env into the environment and then calls Load with the
+// file named by CONFIG_FILE, if any.
 func LoadConfig() (*Config, error) {
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found")
 	}
 
-	timeoutSeconds, err := strconv.Atoi(getEnv("TIMEOUT_SECONDS", "30"))
+	return Load(os.Getenv("CONFIG_FILE"))
+}
+
+// Load builds the configuration from the environment layered over the YAML or
+// TOML file at path (none when empty) and the defaults, then validates it.
+// Every problem found is reported in the returned error; the Config is still
+// returned alongside validation errors so it can be inspected, but must not be
+// used to run anything.
+func Load(path string) (*Config, error) {
+	l, err := newLoader(path)
 	if err != nil {
-		timeoutSeconds = 30
+		return nil, err
 	}
 
-	return &Config{
+	cfg := &Config{
+		Log: LogConfig{
+			Level:  l.string("LOG_LEVEL", "info"),
+			Format: l.string("LOG_FORMAT", "json"),
+		},
+		Auth: AuthConfig{
+			Enabled:    l.bool("AUTH_ENABLED", true),
+			PublicRead: l.bool("AUTH_PUBLIC_READ", false),
+		},
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
-			Host: getEnv("SERVER_HOST", "0.0.0.0"),
-			Mode: getEnv("GIN_MODE", "release"),
+			Port: l.string("SERVER_PORT", "8080"),
+			Host: l.string("SERVER_HOST", "0.0.0.0"),
+			Mode: l.string("GIN_MODE", "release"),
+
+			TrustedProxies: l.list("TRUSTED_PROXIES", nil),
+			TLS: TLSConfig{
+				CertFile:       l.string("TLS_CERT_FILE", ""),
+				KeyFile:        l.string("TLS_KEY_FILE", ""),
+				MinVersion:     l.string("TLS_MIN_VERSION", "1.2"),
+				ClientCAFile:   l.string("TLS_CLIENT_CA_FILE", ""),
+				ClientAuth:     l.string("TLS_CLIENT_AUTH", ""),
+				ReloadInterval: l.duration("TLS_RELOAD_INTERVAL", time.Minute),
+			},
+			SecurityHeaders: l.string("SECURITY_HEADERS", "auto"),
+			HSTSMaxAge:      l.duration("HSTS_MAX_AGE", 365*24*time.Hour),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: l.list("CORS_ALLOWED_ORIGINS", []string{"*"}),
+			AllowedHeaders: l.list("CORS_ALLOWED_HEADERS", []string{
+				"Authorization", "Content-Type", "X-API-Key", "X-Request-ID",
+				"If-None-Match", "If-Modified-Since", "Range", "If-Range",
+			}),
+			ExposedHeaders: l.list("CORS_EXPOSED_HEADERS", []string{
+				"X-Image-ID", "X-Image-Hash", "X-Cat-Source", "X-Upstream-Attempts", "X-Request-ID",
+				"ETag", "Content-Location", "Location", "Content-Disposition", "Content-Range", "Accept-Ranges",
+				"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After",
+			}),
+			AllowCredentials: l.bool("CORS_ALLOW_CREDENTIALS", false),
+			MaxAge:           l.duration("CORS_MAX_AGE", 10*time.Minute),
+		},
+		RateLimit: RateLimitConfig{
+			Backend: l.string("RATE_LIMIT_BACKEND", "memory"),
+			Read:    l.rateLimit("RATE_LIMIT_READ", "600/m"),
+			Fetch:   l.rateLimit("RATE_LIMIT_FETCH", "30/m"),
+			Write:   l.rateLimit("RATE_LIMIT_WRITE", "10/m"),
+			Admin:   l.rateLimit("RATE_LIMIT_ADMIN", "60/m"),
+			Auth:    l.rateLimit("RATE_LIMIT_AUTH", "1200/m"),
 		},
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "5432"),
-			User:     getEnv("DB_USER", "postgres"),
-			Password: getEnv("DB_PASSWORD", "postgres"),
-			DBName:   getEnv("DB_NAME", "catdb"),
-			SSLMode:  getEnv("DB_SSLMODE", "disable"),
+			Host:     l.string("DB_HOST", "localhost"),
+			Port:     l.string("DB_PORT", "5432"),
+			User:     l.string("DB_USER", "postgres"),
+			Password: l.string("DB_PASSWORD", "postgres"),
+			DBName:   l.string("DB_NAME", "catdb"),
+			SSLMode:  l.string("DB_SSLMODE", "disable"),
+
+			AutoMigrate: l.bool("AUTO_MIGRATE", false),
 		},
 		App: AppConfig{
-			CataasAPIURL:   getEnv("CATAAS_API_URL", "https://cataas.com"),
-			TimeoutSeconds: timeoutSeconds,
+			CataasAPIURL:   l.string("CATAAS_API_URL", "https://cataas.com"),
+			TimeoutSeconds: l.int("TIMEOUT_SECONDS", 30),
+			Retry: RetryConfig{
+				MaxAttempts: l.int("CATAAS_RETRY_MAX_ATTEMPTS", 3),
+				BaseBackoff: l.duration("CATAAS_RETRY_BASE_BACKOFF", 200*time.Millisecond),
+				MaxBackoff:  l.duration("CATAAS_RETRY_MAX_BACKOFF", 2*time.Second),
+				Jitter:      l.float("CATAAS_RETRY_JITTER", 0.2),
+			},
+			Breaker: BreakerConfig{
+				FailureRate:    l.float("CATAAS_BREAKER_FAILURE_RATE", 0.5),
+				MinRequests:    l.int("CATAAS_BREAKER_MIN_REQUESTS", 5),
+				Window:         l.duration("CATAAS_BREAKER_WINDOW", 30*time.Second),
+				CoolDown:       l.duration("CATAAS_BREAKER_COOLDOWN", 15*time.Second),
+				HalfOpenProbes: l.int("CATAAS_BREAKER_HALF_OPEN_PROBES", 1),
+			},
+			BlobStore: BlobStoreConfig{
+				Backend:     l.string("BLOB_STORE_BACKEND", "local"),
+				LocalPath:   l.string("BLOB_STORE_PATH", "./data/images"),
+				S3Endpoint:  l.string("S3_ENDPOINT", ""),
+				S3Region:    l.string("S3_REGION", "us-east-1"),
+				S3Bucket:    l.string("S3_BUCKET", ""),
+				S3AccessKey: l.string("S3_ACCESS_KEY", ""),
+				S3SecretKey: l.string("S3_SECRET_KEY", ""),
+				S3UseSSL:    l.bool("S3_USE_SSL", true),
+			},
+			Tracing: TracingConfig{
+				Exporter:     l.string("TRACING_EXPORTER", "none"),
+				OTLPEndpoint: l.string("TRACING_OTLP_ENDPOINT", ""),
+				SampleRatio:  l.float("TRACING_SAMPLE_RATIO", 1.0),
+				ServiceName:  l.string("TRACING_SERVICE_NAME", "cat-api"),
+			},
+			Upload: UploadConfig{
+				MaxBytes:  l.int64("UPLOAD_MAX_BYTES", 10<<20),
+				MaxPixels: l.int("UPLOAD_MAX_PIXELS", 40_000_000),
+			},
+			DeletedImagePolicy: l.string("DELETED_IMAGE_POLICY", "hide"),
+			Retention: RetentionConfig{
+				Interval:  l.duration("RETENTION_INTERVAL", time.Hour),
+				MaxBytes:  l.int64("RETENTION_MAX_BYTES", 0),
+				MaxImages: l.int64("RETENTION_MAX_IMAGES", 0),
+				MaxAge:    l.duration("RETENTION_MAX_AGE", 0),
+				Policy:    l.string("RETENTION_POLICY", "lru"),
+				DryRun:    l.bool("RETENTION_DRY_RUN", false),
+			},
 		},
-	}, nil
+	}
+	cfg.settings = l.settings
+
+	l.checkUnknown()
+	errs := l.errs
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return cfg, fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return cfg, nil
+}
+
+// Settings lists every variable with its effective value and source, in the
+// order they are read.
+func (c *Config) Settings() []Setting {
+	return c.settings
 }
 
 func (c *DatabaseConfig) GetDSN() string {
@@ -71,10 +357,3 @@ func (c *DatabaseConfig) GetDSN() string {
 		c.Host, c.Port, c.User, c.Password, c.DBName, c.SSLMode,
 	)
 }
-
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
\ No newline at end of file
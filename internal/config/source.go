@@ -0,0 +1,333 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	SourceDefault = "default"
+	SourceEnv     = "env"
+)
+
+// secretKeys are redacted by config print. Values read through a *_FILE
+// variable are treated as secrets too.
+var secretKeys = map[string]bool{
+	"DB_PASSWORD":   true,
+	"S3_ACCESS_KEY": true,
+	"S3_SECRET_KEY": true,
+}
+
+// Setting is the effective value of one variable and where it came from:
+// SourceDefault, SourceEnv or the path of the config file. Source gets a
+// " (KEY_FILE)" suffix when the value was read from a secret file.
+type Setting struct {
+	Key    string
+	Value  string
+	Source string
+	Secret bool
+}
+
+// loader resolves every variable from, in order of precedence, the
+// environment, KEY_FILE in the environment, the config file, KEY_FILE in the
+// config file and the default. It collects parse errors instead of falling
+// back to the default so LoadConfig can report them all at once.
+type loader struct {
+	filePath string
+	file     map[string]string
+	known    map[string]bool
+	settings []Setting
+	errs     []error
+}
+
+func newLoader(path string) (*loader, error) {
+	l := &loader{filePath: path, known: make(map[string]bool)}
+	if path == "" {
+		return l, nil
+	}
+
+	file, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	l.file = file
+	return l, nil
+}
+
+// lookup returns the raw value of key. Unless allowEmpty is set an empty
+// value counts as unset, as it always has for environment variables.
+func (l *loader) lookup(key string, allowEmpty bool) (value, source string, ok bool) {
+	l.known[key] = true
+	l.known[key+"_FILE"] = true
+
+	present := func(v string, set bool) bool { return set && (allowEmpty || v != "") }
+
+	envValue, envSet := os.LookupEnv(key)
+	envFile := os.Getenv(key + "_FILE")
+	switch {
+	case present(envValue, envSet) && envFile != "":
+		l.errs = append(l.errs, fmt.Errorf("%s: both %s and %s_FILE are set", key, key, key))
+		return envValue, SourceEnv, true
+	case present(envValue, envSet):
+		return envValue, SourceEnv, true
+	case envFile != "":
+		return l.readSecret(key, envFile, SourceEnv)
+	}
+
+	fileValue, fileSet := l.file[key]
+	fileSecret := l.file[key+"_FILE"]
+	switch {
+	case present(fileValue, fileSet) && fileSecret != "":
+		l.errs = append(l.errs, fmt.Errorf("%s: both %s and %s_FILE are set in %s", key, key, key, l.filePath))
+		return fileValue, l.filePath, true
+	case present(fileValue, fileSet):
+		return fileValue, l.filePath, true
+	case fileSecret != "":
+		return l.readSecret(key, fileSecret, l.filePath)
+	}
+
+	return "", SourceDefault, false
+}
+
+func (l *loader) readSecret(key, path, source string) (string, string, bool) {
+	source = fmt.Sprintf("%s (%s_FILE)", source, key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s_FILE: failed to read secret: %w", key, err))
+		return "", source, false
+	}
+	return strings.TrimRight(string(data), "\r\n"), source, true
+}
+
+func (l *loader) record(key, value, source string) {
+	l.settings = append(l.settings, Setting{
+		Key:    key,
+		Value:  value,
+		Source: source,
+		Secret: secretKeys[key] || strings.HasSuffix(source, "_FILE)"),
+	})
+}
+
+func (l *loader) invalid(key, kind, value, source string) {
+	l.errs = append(l.errs, fmt.Errorf("%s: invalid %s %q (from %s)", key, kind, value, source))
+}
+
+func (l *loader) string(key, defaultValue string) string {
+	value, source, ok := l.lookup(key, false)
+	if !ok {
+		value = defaultValue
+	}
+	l.record(key, value, source)
+	return value
+}
+
+func (l *loader) bool(key string, defaultValue bool) bool {
+	raw, source, ok := l.lookup(key, false)
+	if !ok {
+		l.record(key, strconv.FormatBool(defaultValue), source)
+		return defaultValue
+	}
+	l.record(key, raw, source)
+
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		l.invalid(key, "boolean", raw, source)
+		return defaultValue
+	}
+	return value
+}
+
+func (l *loader) int(key string, defaultValue int) int {
+	return int(l.int64(key, int64(defaultValue)))
+}
+
+func (l *loader) int64(key string, defaultValue int64) int64 {
+	raw, source, ok := l.lookup(key, false)
+	if !ok {
+		l.record(key, strconv.FormatInt(defaultValue, 10), source)
+		return defaultValue
+	}
+	l.record(key, raw, source)
+
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		l.invalid(key, "integer", raw, source)
+		return defaultValue
+	}
+	return value
+}
+
+func (l *loader) float(key string, defaultValue float64) float64 {
+	raw, source, ok := l.lookup(key, false)
+	if !ok {
+		l.record(key, strconv.FormatFloat(defaultValue, 'f', -1, 64), source)
+		return defaultValue
+	}
+	l.record(key, raw, source)
+
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		l.invalid(key, "number", raw, source)
+		return defaultValue
+	}
+	return value
+}
+
+func (l *loader) duration(key string, defaultValue time.Duration) time.Duration {
+	raw, source, ok := l.lookup(key, false)
+	if !ok {
+		l.record(key, defaultValue.String(), source)
+		return defaultValue
+	}
+	l.record(key, raw, source)
+
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		l.invalid(key, "duration", raw, source)
+		return defaultValue
+	}
+	return value
+}
+
+// list splits a comma-separated value. Unlike the other kinds, setting it to
+// an empty string yields an empty list rather than the default.
+func (l *loader) list(key string, defaultValue []string) []string {
+	raw, source, ok := l.lookup(key, true)
+	if !ok {
+		l.record(key, strings.Join(defaultValue, ","), source)
+		return defaultValue
+	}
+
+	var list []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	l.record(key, strings.Join(list, ","), source)
+	return list
+}
+
+func (l *loader) rateLimit(key, defaultRate string) RateLimitRule {
+	return RateLimitRule{
+		Rate:  l.string(key, defaultRate),
+		Burst: l.int(key+"_BURST", 0),
+	}
+}
+
+// checkUnknown reports config file entries that match no variable, so a typo
+// does not silently leave the default in place.
+func (l *loader) checkUnknown() {
+	var unknown []string
+	for key := range l.file {
+		if !l.known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		l.errs = append(l.errs, fmt.Errorf("%s: unknown setting in %s", key, l.filePath))
+	}
+}
+
+// readConfigFile parses a YAML or TOML file into variable names. Nested keys
+// are joined with underscores and upper-cased, so
+//
+//	db:
+//	  password_file: /run/secrets/db
+//
+// sets DB_PASSWORD_FILE. Lists become comma-separated values.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	tree := make(map[string]interface{})
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q (want .yaml, .yml or .toml)", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flatten("", tree, values); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+func flatten(prefix string, tree map[string]interface{}, values map[string]string) error {
+	for name, node := range tree {
+		key := strings.ToUpper(name)
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+
+		if child, ok := node.(map[string]interface{}); ok {
+			if err := flatten(key, child, values); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, err := scalar(node)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+
+		if _, dup := values[key]; dup {
+			return fmt.Errorf("%s is set more than once", key)
+		}
+		values[key] = value
+	}
+	return nil
+}
+
+func scalar(node interface{}) (string, error) {
+	switch v := node.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			if _, nested := item.([]interface{}); nested {
+				return "", fmt.Errorf("nested lists are not supported")
+			}
+			part, err := scalar(item)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value of type %T (quote it as a string)", node)
+	}
+}
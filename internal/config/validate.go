@@ -0,0 +1,212 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validate checks every setting and returns all the problems found joined
+// together, each prefixed with the variable it concerns.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.port("SERVER_PORT", c.Server.Port)
+	v.oneOf("GIN_MODE", c.Server.Mode, "debug", "release", "test")
+	for _, proxy := range c.Server.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				v.add("TRUSTED_PROXIES", "%q is not an IP address or CIDR", proxy)
+			}
+		}
+	}
+	c.Server.TLS.validate(v)
+	v.oneOf("SECURITY_HEADERS", c.Server.SecurityHeaders, "auto", "on", "off")
+	v.nonNegative("HSTS_MAX_AGE", c.Server.HSTSMaxAge)
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		v.add("LOG_LEVEL", "%q is not a log level (want debug, info, warn or error)", c.Log.Level)
+	}
+	v.oneOf("LOG_FORMAT", c.Log.Format, "json", "text")
+
+	v.oneOf("RATE_LIMIT_BACKEND", c.RateLimit.Backend, "memory", "postgres", "none")
+	for _, group := range []struct {
+		key  string
+		rule RateLimitRule
+	}{
+		{"RATE_LIMIT_READ", c.RateLimit.Read},
+		{"RATE_LIMIT_FETCH", c.RateLimit.Fetch},
+		{"RATE_LIMIT_WRITE", c.RateLimit.Write},
+		{"RATE_LIMIT_ADMIN", c.RateLimit.Admin},
+		{"RATE_LIMIT_AUTH", c.RateLimit.Auth},
+	} {
+		if _, _, err := ParseRate(group.rule.Rate); err != nil {
+			v.add(group.key, "%v", err)
+		}
+		if group.rule.Burst < 0 {
+			v.add(group.key+"_BURST", "must not be negative, got %d", group.rule.Burst)
+		}
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" && c.CORS.AllowCredentials {
+			v.add("CORS_ALLOWED_ORIGINS", `"*" cannot be combined with CORS_ALLOW_CREDENTIALS`)
+		}
+		if _, err := path.Match(origin, ""); err != nil {
+			v.add("CORS_ALLOWED_ORIGINS", "invalid pattern %q", origin)
+		}
+	}
+	v.nonNegative("CORS_MAX_AGE", c.CORS.MaxAge)
+
+	v.required("DB_HOST", c.Database.Host)
+	v.port("DB_PORT", c.Database.Port)
+	v.required("DB_USER", c.Database.User)
+	v.required("DB_NAME", c.Database.DBName)
+	v.oneOf("DB_SSLMODE", c.Database.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+
+	c.App.validate(v)
+
+	return errors.Join(v.errs...)
+}
+
+func (c *TLSConfig) validate(v *validator) {
+	if c.Enabled() && (c.CertFile == "" || c.KeyFile == "") {
+		v.add("TLS_CERT_FILE", "TLS needs both TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+	v.oneOf("TLS_MIN_VERSION", c.MinVersion, "1.2", "1.3")
+	// Same values as tlsconfig.New.
+	v.oneOf("TLS_CLIENT_AUTH", c.ClientAuth, "", "require", "optional")
+	if c.ClientAuth != "" && c.ClientCAFile == "" {
+		v.add("TLS_CLIENT_AUTH", "needs TLS_CLIENT_CA_FILE")
+	}
+	if c.Enabled() {
+		v.positive("TLS_RELOAD_INTERVAL", c.ReloadInterval)
+	}
+}
+
+func (c *AppConfig) validate(v *validator) {
+	if u, err := url.Parse(c.CataasAPIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add("CATAAS_API_URL", "%q is not an http(s) URL", c.CataasAPIURL)
+	}
+	if c.TimeoutSeconds <= 0 {
+		v.add("TIMEOUT_SECONDS", "must be positive, got %d", c.TimeoutSeconds)
+	}
+
+	if c.Retry.MaxAttempts < 1 {
+		v.add("CATAAS_RETRY_MAX_ATTEMPTS", "must be at least 1, got %d", c.Retry.MaxAttempts)
+	}
+	v.nonNegative("CATAAS_RETRY_BASE_BACKOFF", c.Retry.BaseBackoff)
+	if c.Retry.MaxBackoff < c.Retry.BaseBackoff {
+		v.add("CATAAS_RETRY_MAX_BACKOFF", "must not be below CATAAS_RETRY_BASE_BACKOFF (%s), got %s", c.Retry.BaseBackoff, c.Retry.MaxBackoff)
+	}
+	v.fraction("CATAAS_RETRY_JITTER", c.Retry.Jitter)
+
+	if c.Breaker.FailureRate <= 0 || c.Breaker.FailureRate > 1 {
+		v.add("CATAAS_BREAKER_FAILURE_RATE", "must be in (0, 1], got %g", c.Breaker.FailureRate)
+	}
+	if c.Breaker.MinRequests < 1 {
+		v.add("CATAAS_BREAKER_MIN_REQUESTS", "must be at least 1, got %d", c.Breaker.MinRequests)
+	}
+	v.positive("CATAAS_BREAKER_WINDOW", c.Breaker.Window)
+	v.positive("CATAAS_BREAKER_COOLDOWN", c.Breaker.CoolDown)
+	if c.Breaker.HalfOpenProbes < 1 {
+		v.add("CATAAS_BREAKER_HALF_OPEN_PROBES", "must be at least 1, got %d", c.Breaker.HalfOpenProbes)
+	}
+
+	switch c.BlobStore.Backend {
+	case "local":
+		v.required("BLOB_STORE_PATH", c.BlobStore.LocalPath)
+	case "s3":
+		v.required("S3_ENDPOINT", c.BlobStore.S3Endpoint)
+		v.required("S3_BUCKET", c.BlobStore.S3Bucket)
+	default:
+		v.oneOf("BLOB_STORE_BACKEND", c.BlobStore.Backend, "local", "s3")
+	}
+
+	v.oneOf("TRACING_EXPORTER", c.Tracing.Exporter, "none", "otlp", "stdout")
+	v.fraction("TRACING_SAMPLE_RATIO", c.Tracing.SampleRatio)
+	v.required("TRACING_SERVICE_NAME", c.Tracing.ServiceName)
+
+	if c.Upload.MaxBytes <= 0 {
+		v.add("UPLOAD_MAX_BYTES", "must be positive, got %d", c.Upload.MaxBytes)
+	}
+	if c.Upload.MaxPixels <= 0 {
+		v.add("UPLOAD_MAX_PIXELS", "must be positive, got %d", c.Upload.MaxPixels)
+	}
+	v.oneOf("DELETED_IMAGE_POLICY", c.DeletedImagePolicy, "hide", "restore")
+
+	r := c.Retention
+	v.oneOf("RETENTION_POLICY", r.Policy, "lru", "lfu")
+	if r.MaxBytes < 0 {
+		v.add("RETENTION_MAX_BYTES", "must not be negative, got %d", r.MaxBytes)
+	}
+	if r.MaxImages < 0 {
+		v.add("RETENTION_MAX_IMAGES", "must not be negative, got %d", r.MaxImages)
+	}
+	v.nonNegative("RETENTION_MAX_AGE", r.MaxAge)
+	if r.MaxBytes > 0 || r.MaxImages > 0 || r.MaxAge > 0 {
+		v.positive("RETENTION_INTERVAL", r.Interval)
+	}
+}
+
+type validator struct {
+	errs []error
+}
+
+func (v *validator) add(key, format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) required(key, value string) {
+	if value == "" {
+		v.add(key, "must be set")
+	}
+}
+
+func (v *validator) oneOf(key, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+
+	quoted := make([]string, 0, len(allowed))
+	for _, a := range allowed {
+		if a != "" {
+			quoted = append(quoted, strconv.Quote(a))
+		}
+	}
+	v.add(key, "unknown value %q (want %s)", value, strings.Join(quoted, ", "))
+}
+
+func (v *validator) port(key, value string) {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		v.add(key, "%q is not a port number (1-65535)", value)
+	}
+}
+
+func (v *validator) positive(key string, d time.Duration) {
+	if d <= 0 {
+		v.add(key, "must be positive, got %s", d)
+	}
+}
+
+func (v *validator) nonNegative(key string, d time.Duration) {
+	if d < 0 {
+		v.add(key, "must not be negative, got %s", d)
+	}
+}
+
+func (v *validator) fraction(key string, f float64) {
+	if f < 0 || f > 1 {
+		v.add(key, "must be between 0 and 1, got %g", f)
+	}
+}
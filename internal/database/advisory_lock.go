@@ -0,0 +1,47 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// AdvisoryLock is a pg_advisory_lock key that lets one replica at a time run
+// a background job against the shared database.
+type AdvisoryLock struct {
+	db *sql.DB
+	id int64
+}
+
+func NewAdvisoryLock(d *Database, id int64) (*AdvisoryLock, error) {
+	sqlDB, err := d.DB.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database: %w", err)
+	}
+	return &AdvisoryLock{db: sqlDB, id: id}, nil
+}
+
+// TryLock takes the lock without waiting. ok is false when another session
+// holds it; otherwise unlock must be called to release it.
+func (l *AdvisoryLock) TryLock(ctx context.Context) (unlock func(), ok bool, err error) {
+	// Advisory locks belong to a session, so the connection is kept until
+	// unlock.
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.id).Scan(&ok); err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("failed to acquire advisory lock: %w", err)
+	}
+	if !ok {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	return func() {
+		conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", l.id)
+		conn.Close()
+	}, true, nil
+}
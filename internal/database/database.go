@@ -1,10 +1,10 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"time"
 	"github.com/IavilaGw/cat-api/internal/config"
-	"github.com/IavilaGw/cat-api/internal/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -40,15 +40,6 @@ func NewDatabase(cfg *config.DatabaseConfig) (*Database, error) {
 	return &Database{DB: db}, nil
 }
 
-func (d *Database) AutoMigrate() error {
-	
-	if err := d.DB.AutoMigrate(&models.CatImage{}); err != nil {
-		return fmt.Errorf("failed to migrate: %w", err)
-	}
-
-	return nil
-}
-
 func (d *Database) Close() error {
 	sqlDB, err := d.DB.DB()
 	if err != nil {
@@ -57,10 +48,10 @@ func (d *Database) Close() error {
 	return sqlDB.Close()
 }
 
-func (d *Database) HealthCheck() error {
+func (d *Database) HealthCheck(ctx context.Context) error {
 	sqlDB, err := d.DB.DB()
 	if err != nil {
 		return err
 	}
-	return sqlDB.Ping()
+	return sqlDB.PingContext(ctx)
 }
\ No newline at end of file
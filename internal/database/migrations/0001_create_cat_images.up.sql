@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS cat_images (
+    id               bigserial PRIMARY KEY,
+    image_hash       varchar(64) NOT NULL,
+    content_type     varchar(50) NOT NULL,
+    size             bigint NOT NULL,
+    created_at       timestamptz NOT NULL,
+    last_accessed_at timestamptz NOT NULL,
+    access_count     bigint DEFAULT 0,
+    deleted_at       timestamptz
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cat_images_image_hash ON cat_images (image_hash);
+CREATE INDEX IF NOT EXISTS idx_cat_images_deleted_at ON cat_images (deleted_at);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_cat_images_variant_tag;
+
+ALTER TABLE cat_images
+    DROP COLUMN IF EXISTS variant_tag,
+    DROP COLUMN IF EXISTS variant_says,
+    DROP COLUMN IF EXISTS variant_filter,
+    DROP COLUMN IF EXISTS variant_width,
+    DROP COLUMN IF EXISTS variant_height,
+    DROP COLUMN IF EXISTS variant_type;
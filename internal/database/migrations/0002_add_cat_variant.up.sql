@@ -0,0 +1,9 @@
+ALTER TABLE cat_images
+    ADD COLUMN IF NOT EXISTS variant_tag    varchar(100),
+    ADD COLUMN IF NOT EXISTS variant_says   varchar(255),
+    ADD COLUMN IF NOT EXISTS variant_filter varchar(20),
+    ADD COLUMN IF NOT EXISTS variant_width  bigint DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS variant_height bigint DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS variant_type   varchar(20);
+
+CREATE INDEX IF NOT EXISTS idx_cat_images_variant_tag ON cat_images (variant_tag);
@@ -0,0 +1,2 @@
+-- Bytes already moved to the blob store are not copied back.
+ALTER TABLE cat_images DROP COLUMN IF EXISTS storage_key;
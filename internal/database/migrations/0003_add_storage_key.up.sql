@@ -0,0 +1,13 @@
+ALTER TABLE cat_images ADD COLUMN IF NOT EXISTS storage_key varchar(255) NOT NULL DEFAULT '';
+
+-- Tables created before the blob store keep their bytes in image_data until
+-- `migrate-blobs` runs; new rows leave it empty.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'cat_images' AND column_name = 'image_data'
+    ) THEN
+        ALTER TABLE cat_images ALTER COLUMN image_data DROP NOT NULL;
+    END IF;
+END $$;
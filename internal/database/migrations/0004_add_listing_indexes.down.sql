@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_cat_images_content_type_created_at;
+DROP INDEX IF EXISTS idx_cat_images_last_accessed_at_id;
+DROP INDEX IF EXISTS idx_cat_images_size_id;
+DROP INDEX IF EXISTS idx_cat_images_access_count_id;
+DROP INDEX IF EXISTS idx_cat_images_created_at_id;
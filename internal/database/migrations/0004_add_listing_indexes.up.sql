@@ -0,0 +1,7 @@
+-- Keyset pagination for GET /api/images orders by (column, id); these
+-- indexes let every sort order and the content type filter seek directly.
+CREATE INDEX IF NOT EXISTS idx_cat_images_created_at_id ON cat_images (created_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_cat_images_access_count_id ON cat_images (access_count, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_cat_images_size_id ON cat_images (size, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_cat_images_last_accessed_at_id ON cat_images (last_accessed_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_cat_images_content_type_created_at ON cat_images (content_type, created_at, id) WHERE deleted_at IS NULL;
@@ -0,0 +1,10 @@
+-- Token buckets for RATE_LIMIT_BACKEND=postgres. Losing them on a crash only
+-- resets the limits, so the table skips the WAL.
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_buckets (
+    key        varchar(255) PRIMARY KEY,
+    tokens     double precision NOT NULL,
+    updated_at timestamptz NOT NULL,
+    expires_at timestamptz NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_expires_at ON rate_limit_buckets (expires_at);
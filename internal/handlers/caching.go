@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/IavilaGw/cat-api/internal/models"
+)
+
+// Stored images never change once saved, so clients and CDNs may keep them
+// for as long as they like.
+const imageCacheControl = "public, max-age=31536000, immutable"
+
+// imageETag is a strong validator: the ETag is the SHA-256 of the bytes.
+func imageETag(catImage *models.CatImage) string {
+	return `"` + catImage.ImageHash + `"`
+}
+
+func setImageCacheHeaders(c *gin.Context, catImage *models.CatImage) {
+	c.Header("ETag", imageETag(catImage))
+	c.Header("Cache-Control", imageCacheControl)
+	if !catImage.CreatedAt.IsZero() {
+		c.Header("Last-Modified", catImage.CreatedAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// notModified reports whether the request's validators still match the
+// stored image. If-None-Match takes precedence over If-Modified-Since, as
+// required by RFC 9110.
+func notModified(r *http.Request, catImage *models.CatImage) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagListMatches(inm, imageETag(catImage))
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !catImage.CreatedAt.IsZero() {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		return !catImage.CreatedAt.Truncate(time.Second).After(since)
+	}
+
+	return false
+}
+
+// etagListMatches uses the weak comparison If-None-Match calls for, so a
+// W/ prefix added by a proxy still matches.
+func etagListMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// countsAsAccess is false for ranged requests unless the range covers the
+// whole image, so probing the first bytes or fetching an image in chunks does
+// not inflate the access stats that drive retention.
+func countsAsAccess(r *http.Request, size int64) bool {
+	rangeHeader := strings.TrimSpace(r.Header.Get("Range"))
+	if rangeHeader == "" {
+		return true
+	}
+
+	spec, ok := strings.CutPrefix(rangeHeader, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return false
+	}
+	start, end, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok || strings.TrimSpace(start) != "0" {
+		return false
+	}
+	if end = strings.TrimSpace(end); end == "" {
+		return true
+	}
+	last, err := strconv.ParseInt(end, 10, 64)
+	return err == nil && last >= size-1
+}
@@ -1,27 +1,67 @@
 package handlers
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 	"github.com/gin-gonic/gin"
+	"github.com/IavilaGw/cat-api/internal/breaker"
+	"github.com/IavilaGw/cat-api/internal/models"
 	"github.com/IavilaGw/cat-api/internal/services"
+	"github.com/IavilaGw/cat-api/pkg/client"
 )
 
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+const uploadField = "image"
+
 type CatHandler struct {
 	catService *services.CatService
+	logger     *slog.Logger
 }
 
-func NewCatHandler(catService *services.CatService) *CatHandler {
-	return &CatHandler{catService: catService}
+func NewCatHandler(catService *services.CatService, logger *slog.Logger) *CatHandler {
+	return &CatHandler{catService: catService, logger: logger}
 }
 
 func (h *CatHandler) GetRandomCat(c *gin.Context) {
-	log.Println("GET /api/cat")
+	opts, err := parseCatOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query parameters",
+			"message": err.Error(),
+		})
+		return
+	}
 
-	catImage, imageData, err := h.catService.FetchAndSaveRandomCat()
+	result, err := h.catService.FetchAndSaveRandomCat(c.Request.Context(), opts)
+	if errors.Is(err, services.ErrInvalidOptions) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query parameters",
+			"message": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, breaker.ErrOpen) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Cat provider unavailable",
+			"message": "cataas.com is failing, requests are paused for a moment; try again later",
+		})
+		return
+	}
 	if err != nil {
-		log.Printf("Error: %v", err)
+		var upstreamErr *client.UpstreamError
+		if errors.As(err, &upstreamErr) {
+			c.Header("X-Upstream-Attempts", strconv.Itoa(upstreamErr.Attempts))
+		}
+		h.logger.ErrorContext(c.Request.Context(), "failed to fetch cat image", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to fetch cat image",
 			"message": err.Error(),
@@ -29,17 +69,24 @@ func (h *CatHandler) GetRandomCat(c *gin.Context) {
 		return
 	}
 
-	c.Header("X-Image-ID", strconv.FormatUint(uint64(catImage.ID), 10))
-	c.Header("X-Image-Hash", catImage.ImageHash)
-	c.Data(http.StatusOK, catImage.ContentType, imageData)
+	h.logger.InfoContext(c.Request.Context(), "served cat image",
+		"image_id", result.Image.ID,
+		"source", result.Source,
+		"upstream_attempts", result.Attempts,
+	)
+
+	c.Header("X-Image-ID", strconv.FormatUint(uint64(result.Image.ID), 10))
+	c.Header("X-Image-Hash", result.Image.ImageHash)
+	c.Header("X-Upstream-Attempts", strconv.Itoa(result.Attempts))
+	c.Header("X-Cat-Source", result.Source)
+	c.Header("Content-Location", imageHashURL(result.Image))
+	c.Data(http.StatusOK, result.Image.ContentType, result.Data)
 }
 
 func (h *CatHandler) GetCount(c *gin.Context) {
-	log.Println("GET /api/count")
-
-	count, err := h.catService.GetUniqueImageCount()
+	count, err := h.catService.GetUniqueImageCount(c.Request.Context())
 	if err != nil {
-		log.Printf("Error: %v", err)
+		h.logger.ErrorContext(c.Request.Context(), "failed to get count", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to get count",
 			"message": err.Error(),
@@ -53,11 +100,9 @@ func (h *CatHandler) GetCount(c *gin.Context) {
 }
 
 func (h *CatHandler) GetStats(c *gin.Context) {
-	log.Println("GET /api/stats")
-
-	stats, err := h.catService.GetStats()
+	stats, err := h.catService.GetStats(c.Request.Context())
 	if err != nil {
-		log.Printf("Error: %v", err)
+		h.logger.ErrorContext(c.Request.Context(), "failed to get stats", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to get stats",
 			"message": err.Error(),
@@ -69,25 +114,398 @@ func (h *CatHandler) GetStats(c *gin.Context) {
 }
 
 func (h *CatHandler) GetImageByID(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
+	id, ok := parseImageID(c)
+	if !ok {
+		return
+	}
+
+	catImage, err := h.catService.GetCatImageByID(c.Request.Context(), id)
+	if errors.Is(err, services.ErrImageNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Image not found",
+		})
+		return
+	}
 	if err != nil {
+		h.logger.ErrorContext(c.Request.Context(), "failed to get image", "image_id", id, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get image",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	h.serveImage(c, catImage)
+}
+
+// GetImageByHash serves the same image as GetImageByID, addressed by its
+// SHA-256 so links survive database rebuilds.
+func (h *CatHandler) GetImageByHash(c *gin.Context) {
+	hash := strings.ToLower(c.Param("sha256"))
+	if !sha256Pattern.MatchString(hash) {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid ID",
+			"error": "Invalid hash",
+		})
+		return
+	}
+
+	catImage, err := h.catService.GetCatImageByHash(c.Request.Context(), hash)
+	if errors.Is(err, services.ErrImageNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Image not found",
+		})
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(c.Request.Context(), "failed to get image", "image_hash", hash, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get image",
+			"message": err.Error(),
 		})
 		return
 	}
 
-	log.Printf("GET /api/image/%d", id)
+	h.serveImage(c, catImage)
+}
+
+// serveImage writes the bytes of catImage honoring conditional and range
+// headers. HEAD answers from the metadata alone.
+func (h *CatHandler) serveImage(c *gin.Context, catImage *models.CatImage) {
+	// A revalidation is still a view, but existence checks and partial
+	// downloads are not.
+	if c.Request.Method == http.MethodGet && countsAsAccess(c.Request, catImage.Size) {
+		if err := h.catService.RecordAccess(c.Request.Context(), catImage); err != nil {
+			h.logger.WarnContext(c.Request.Context(), "failed to record image access", "image_id", catImage.ID, "error", err)
+		}
+	}
 
-	catImage, err := h.catService.GetCatImageByID(uint(id))
+	if notModified(c.Request, catImage) {
+		setImageCacheHeaders(c, catImage)
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	if c.Request.Method == http.MethodHead {
+		c.Header("X-Image-Hash", catImage.ImageHash)
+		c.Header("Content-Type", catImage.ContentType)
+		c.Header("Content-Length", strconv.FormatInt(catImage.Size, 10))
+		c.Header("Accept-Ranges", "bytes")
+		setImageCacheHeaders(c, catImage)
+		c.Status(http.StatusOK)
+		return
+	}
+
+	reader, err := h.catService.OpenImageData(c.Request.Context(), catImage)
 	if err != nil {
+		h.logger.ErrorContext(c.Request.Context(), "failed to read image", "image_id", catImage.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read image",
+			"message": err.Error(),
+		})
+		return
+	}
+	defer reader.Close()
+
+	c.Header("X-Image-Hash", catImage.ImageHash)
+	c.Header("Content-Type", catImage.ContentType)
+	setImageCacheHeaders(c, catImage)
+	if download, _ := strconv.ParseBool(c.Query("download")); download {
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": downloadFilename(catImage),
+		}))
+	}
+	// ServeContent handles Range, If-Range, multipart/byteranges and 416.
+	http.ServeContent(c.Writer, c.Request, "", catImage.CreatedAt, reader)
+}
+
+// GetImageMeta returns the stored metadata without the bytes. It does not
+// count as an access.
+func (h *CatHandler) GetImageMeta(c *gin.Context) {
+	id, ok := parseImageID(c)
+	if !ok {
+		return
+	}
+
+	catImage, err := h.catService.GetCatImageByID(c.Request.Context(), id)
+	if errors.Is(err, services.ErrImageNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "Image not found",
 		})
 		return
 	}
+	if err != nil {
+		h.logger.ErrorContext(c.Request.Context(), "failed to get image", "image_id", id, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get image",
+			"message": err.Error(),
+		})
+		return
+	}
 
-	c.Header("X-Image-Hash", catImage.ImageHash)
-	c.Data(http.StatusOK, catImage.ContentType, catImage.ImageData)
-}
\ No newline at end of file
+	c.JSON(http.StatusOK, catImage)
+}
+
+func (h *CatHandler) ListImages(c *gin.Context) {
+	opts, err := parseListImagesOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query parameters",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	page, err := h.catService.ListImages(c.Request.Context(), opts)
+	if errors.Is(err, services.ErrInvalidOptions) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query parameters",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(c.Request.Context(), "failed to list images", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list images",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// UploadImage stores an image sent either as the raw request body or as the
+// "image" field of a multipart form. Duplicates answer 200 with the image
+// already stored.
+func (h *CatHandler) UploadImage(c *gin.Context) {
+	body, err := uploadBody(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid upload",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	catImage, created, err := h.catService.UploadImage(c.Request.Context(), body)
+	switch {
+	case errors.Is(err, services.ErrImageTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":   "Image too large",
+			"message": err.Error(),
+		})
+		return
+	case errors.Is(err, services.ErrUnsupportedImage):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error":   "Unsupported image type",
+			"message": err.Error(),
+		})
+		return
+	case errors.Is(err, services.ErrInvalidImage):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Invalid image",
+			"message": err.Error(),
+		})
+		return
+	case errors.Is(err, services.ErrImageDeleted):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Image was deleted",
+			"message": "this image was deleted and cannot be uploaded again",
+		})
+		return
+	case err != nil:
+		h.logger.ErrorContext(c.Request.Context(), "failed to upload image", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to upload image",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	h.logger.InfoContext(c.Request.Context(), "uploaded image", "image_id", catImage.ID, "created", created)
+
+	if !created {
+		c.Header("Content-Location", imageHashURL(catImage))
+		c.JSON(http.StatusOK, catImage)
+		return
+	}
+	c.Header("Location", imageHashURL(catImage))
+	c.JSON(http.StatusCreated, catImage)
+}
+
+// DeleteImage soft-deletes an image. With ?purge=true the row and its bytes
+// are removed for good, whether or not it was soft-deleted before.
+// PurgeRequested reports whether a DeleteImage request asks for ?purge=true.
+func PurgeRequested(c *gin.Context) bool {
+	purge, _ := strconv.ParseBool(c.Query("purge"))
+	return purge
+}
+
+func (h *CatHandler) DeleteImage(c *gin.Context) {
+	id, ok := parseImageID(c)
+	if !ok {
+		return
+	}
+
+	purge := PurgeRequested(c)
+	var err error
+	if purge {
+		err = h.catService.PurgeImage(c.Request.Context(), id)
+	} else {
+		err = h.catService.DeleteImage(c.Request.Context(), id)
+	}
+	if errors.Is(err, services.ErrImageNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Image not found",
+		})
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(c.Request.Context(), "failed to delete image", "image_id", id, "purge", purge, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to delete image",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	h.logger.InfoContext(c.Request.Context(), "deleted image", "image_id", id, "purge", purge)
+	c.Status(http.StatusNoContent)
+}
+
+func (h *CatHandler) RestoreImage(c *gin.Context) {
+	id, ok := parseImageID(c)
+	if !ok {
+		return
+	}
+
+	catImage, err := h.catService.RestoreImage(c.Request.Context(), id)
+	if errors.Is(err, services.ErrImageNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Image not found",
+		})
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(c.Request.Context(), "failed to restore image", "image_id", id, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to restore image",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	h.logger.InfoContext(c.Request.Context(), "restored image", "image_id", id)
+	c.JSON(http.StatusOK, catImage)
+}
+
+// uploadBody streams the image part of a multipart form without buffering
+// the other parts, or returns the raw body as is.
+func uploadBody(r *http.Request) (io.Reader, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, fmt.Errorf("multipart upload needs an %q file field", uploadField)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == uploadField {
+			return part, nil
+		}
+	}
+}
+
+// imageHashURL is the stable address of a stored image.
+func imageHashURL(catImage *models.CatImage) string {
+	return "/api/image/hash/" + catImage.ImageHash
+}
+
+func parseImageID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid ID",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func parseCatOptions(c *gin.Context) (services.CatOptions, error) {
+	opts := services.CatOptions{
+		Tag:    c.Query("tag"),
+		Says:   c.Query("says"),
+		Filter: c.Query("filter"),
+		Type:   c.Query("type"),
+	}
+
+	var err error
+	if opts.Width, err = parsePositiveInt(c, "width"); err != nil {
+		return opts, err
+	}
+	if opts.Height, err = parsePositiveInt(c, "height"); err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
+
+func parsePositiveInt(c *gin.Context, key string) (int, error) {
+	value := c.Query(key)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer", key)
+	}
+	return n, nil
+}
+
+func parseListImagesOptions(c *gin.Context) (services.ListImagesOptions, error) {
+	opts := services.ListImagesOptions{
+		Cursor:      c.Query("cursor"),
+		Sort:        c.Query("sort"),
+		Order:       c.Query("order"),
+		ContentType: c.Query("content_type"),
+	}
+
+	var err error
+	if opts.Limit, err = parsePositiveInt(c, "limit"); err != nil {
+		return opts, err
+	}
+	if opts.CreatedAfter, err = parseTime(c, "created_after"); err != nil {
+		return opts, err
+	}
+	if opts.CreatedBefore, err = parseTime(c, "created_before"); err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
+
+// parseTime accepts RFC 3339 timestamps or plain dates (midnight UTC).
+func parseTime(c *gin.Context, key string) (time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp or a YYYY-MM-DD date", key)
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"mime"
+
+	"github.com/IavilaGw/cat-api/internal/models"
+)
+
+var imageExtensions = map[string]string{
+	"image/jpeg":    ".jpg",
+	"image/png":     ".png",
+	"image/gif":     ".gif",
+	"image/webp":    ".webp",
+	"image/avif":    ".avif",
+	"image/bmp":     ".bmp",
+	"image/svg+xml": ".svg",
+}
+
+// downloadFilename names a downloaded image after its hash. The extension
+// comes from a fixed table first because mime.ExtensionsByType depends on
+// the host's mime.types and may prefer oddities like .jfif.
+func downloadFilename(catImage *models.CatImage) string {
+	ext := ".bin"
+	if mediaType, _, err := mime.ParseMediaType(catImage.ContentType); err == nil {
+		if known, ok := imageExtensions[mediaType]; ok {
+			ext = known
+		} else if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+			ext = exts[0]
+		}
+	}
+	return catImage.ImageHash + ext
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/IavilaGw/cat-api/internal/breaker"
 	"github.com/IavilaGw/cat-api/internal/database"
 	"github.com/IavilaGw/cat-api/pkg/client"
 )
@@ -11,12 +12,14 @@ import (
 type HealthHandler struct {
 	db           *database.Database
 	cataasClient *client.CataasClient
+	circuit      *breaker.Breaker
 }
 
-func NewHealthHandler(db *database.Database, cataasClient *client.CataasClient) *HealthHandler {
+func NewHealthHandler(db *database.Database, cataasClient *client.CataasClient, circuit *breaker.Breaker) *HealthHandler {
 	return &HealthHandler{
 		db:           db,
 		cataasClient: cataasClient,
+		circuit:      circuit,
 	}
 }
 
@@ -33,20 +36,24 @@ func (h *HealthHandler) Ready(c *gin.Context) {
 	allHealthy := true
 
 	//health
-	if err := h.db.HealthCheck(); err != nil {
+	if err := h.db.HealthCheck(c.Request.Context()); err != nil {
 		checks["database"] = "unhealthy: " + err.Error()
 		allHealthy = false
 	} else {
 		checks["database"] = "healthy"
 	}
 
-	if err := h.cataasClient.HealthCheck(); err != nil {
+	if err := h.cataasClient.HealthCheck(c.Request.Context()); err != nil {
 		checks["cataas_api"] = "unhealthy: " + err.Error()
 		allHealthy = false
 	} else {
 		checks["cataas_api"] = "healthy"
 	}
 
+	if h.circuit != nil {
+		checks["cataas_circuit"] = h.circuit.State().String()
+	}
+
 	status := "ready"
 	statusCode := http.StatusOK
 	
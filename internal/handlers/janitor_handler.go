@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/IavilaGw/cat-api/internal/janitor"
+)
+
+type JanitorHandler struct {
+	janitor *janitor.Janitor
+}
+
+// NewJanitorHandler accepts a nil janitor when retention is disabled.
+func NewJanitorHandler(j *janitor.Janitor) *JanitorHandler {
+	return &JanitorHandler{janitor: j}
+}
+
+// LastRun returns what the most recent janitor run evicted (or, in dry-run
+// mode, would have evicted).
+func (h *JanitorHandler) LastRun(c *gin.Context) {
+	if h.janitor == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Retention is disabled"})
+		return
+	}
+
+	report := h.janitor.LastReport()
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Janitor has not run yet"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
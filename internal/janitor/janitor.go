@@ -0,0 +1,320 @@
+package janitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/IavilaGw/cat-api/internal/models"
+)
+
+const pageSize = 500
+
+const (
+	ReasonAge   = "age"
+	ReasonCount = "count"
+	ReasonBytes = "bytes"
+)
+
+// Store is the part of the repository the janitor needs.
+type Store interface {
+	RetentionTotals(ctx context.Context) (count int64, totalBytes int64, err error)
+	ExpiredImages(ctx context.Context, before time.Time, afterID uint, limit int) ([]models.CatImage, error)
+	EvictionCandidates(ctx context.Context, policy models.EvictionPolicy, offset, limit int) ([]models.CatImage, error)
+	Purge(ctx context.Context, id uint) error
+}
+
+// Locker keeps replicas that share a store from running at the same time.
+// TryLock returns ok false when another replica holds the lock; otherwise
+// unlock must be called once the run is over.
+type Locker interface {
+	TryLock(ctx context.Context) (unlock func(), ok bool, err error)
+}
+
+// ParsePolicy validates a RETENTION_POLICY value.
+func ParsePolicy(s string) (models.EvictionPolicy, error) {
+	switch policy := models.EvictionPolicy(s); policy {
+	case models.EvictLRU, models.EvictLFU:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown eviction policy %q (want lru or lfu)", s)
+	}
+}
+
+// Config limits the image store. A zero limit is not enforced.
+type Config struct {
+	Interval  time.Duration
+	MaxBytes  int64
+	MaxImages int64
+	MaxAge    time.Duration
+	Policy    models.EvictionPolicy
+	DryRun    bool
+}
+
+// Enabled reports whether any limit is set.
+func (c Config) Enabled() bool {
+	return c.MaxBytes > 0 || c.MaxImages > 0 || c.MaxAge > 0
+}
+
+type Eviction struct {
+	ID     uint   `json:"id"`
+	Size   int64  `json:"size"`
+	Reason string `json:"reason"`
+}
+
+// Report describes one janitor run. In dry-run mode Evicted lists what would
+// have been purged and nothing is touched.
+type Report struct {
+	Started     time.Time     `json:"started"`
+	Duration    time.Duration `json:"duration_ns"`
+	DryRun      bool          `json:"dry_run"`
+	ImagesAfter int64         `json:"images_after"`
+	BytesAfter  int64         `json:"bytes_after"`
+	Evicted     []Eviction    `json:"evicted"`
+	Errors      []string      `json:"errors,omitempty"`
+}
+
+// FreedBytes is the total size of the evicted images.
+func (r *Report) FreedBytes() int64 {
+	var freed int64
+	for _, eviction := range r.Evicted {
+		freed += eviction.Size
+	}
+	return freed
+}
+
+// Janitor periodically purges images until the store is within its limits.
+type Janitor struct {
+	store    Store
+	cfg      Config
+	logger   *slog.Logger
+	reporter func(*Report)
+	locker   Locker
+
+	mu   sync.Mutex
+	last *Report
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+func New(store Store, cfg Config, logger *slog.Logger) *Janitor {
+	return &Janitor{
+		store:  store,
+		cfg:    cfg,
+		logger: logger,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// WithReporter registers a callback invoked after every run, e.g. to export
+// metrics.
+func (j *Janitor) WithReporter(reporter func(*Report)) *Janitor {
+	j.reporter = reporter
+	return j
+}
+
+// WithLocker makes every run take the lock first and skip the run when
+// another replica holds it.
+func (j *Janitor) WithLocker(locker Locker) *Janitor {
+	j.locker = locker
+	return j
+}
+
+// LastReport returns the report of the most recent run, or nil before the
+// first one finishes.
+func (j *Janitor) LastReport() *Report {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.last
+}
+
+// Start runs the janitor every Interval until Stop is called. The first run
+// happens right away.
+func (j *Janitor) Start() {
+	go func() {
+		defer close(j.done)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-j.stop
+			cancel()
+		}()
+
+		ticker := time.NewTicker(j.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := j.Run(ctx); err != nil && ctx.Err() == nil {
+				j.logger.Error("janitor run failed", "error", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop interrupts a run in progress and waits for the goroutine to exit.
+func (j *Janitor) Stop() {
+	j.once.Do(func() { close(j.stop) })
+	<-j.done
+}
+
+// Run performs one pass: it plans every eviction first and then purges them,
+// unless DryRun is set. It returns a nil report and no error when the run was
+// skipped because another replica holds the lock.
+func (j *Janitor) Run(ctx context.Context) (*Report, error) {
+	if j.locker != nil {
+		unlock, ok, err := j.locker.TryLock(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			j.logger.Debug("janitor run skipped, another replica holds the lock")
+			return nil, nil
+		}
+		defer unlock()
+	}
+
+	report := &Report{Started: time.Now(), DryRun: j.cfg.DryRun}
+
+	count, totalBytes, err := j.store.RetentionTotals(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	planned, err := j.plan(ctx, count, totalBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	report.ImagesAfter, report.BytesAfter = count, totalBytes
+	for _, eviction := range planned {
+		if !j.cfg.DryRun {
+			if err := j.store.Purge(ctx, eviction.ID); err != nil {
+				if ctx.Err() != nil {
+					break
+				}
+				err = fmt.Errorf("failed to purge image %d: %w", eviction.ID, err)
+				errs = append(errs, err)
+				report.Errors = append(report.Errors, err.Error())
+				continue
+			}
+		}
+		report.Evicted = append(report.Evicted, eviction)
+		report.ImagesAfter--
+		report.BytesAfter -= eviction.Size
+	}
+	report.Duration = time.Since(report.Started)
+
+	j.log(report)
+	j.mu.Lock()
+	j.last = report
+	j.mu.Unlock()
+	if j.reporter != nil {
+		j.reporter(report)
+	}
+
+	if ctx.Err() != nil {
+		return report, ctx.Err()
+	}
+	return report, errors.Join(errs...)
+}
+
+func (j *Janitor) plan(ctx context.Context, count, totalBytes int64) ([]Eviction, error) {
+	var planned []Eviction
+	seen := make(map[uint]bool)
+	evict := func(img models.CatImage, reason string) {
+		planned = append(planned, Eviction{ID: img.ID, Size: img.Size, Reason: reason})
+		seen[img.ID] = true
+		count--
+		totalBytes -= img.Size
+	}
+
+	if j.cfg.MaxAge > 0 {
+		cutoff := time.Now().Add(-j.cfg.MaxAge)
+		var afterID uint
+		for {
+			page, err := j.store.ExpiredImages(ctx, cutoff, afterID, pageSize)
+			if err != nil {
+				return nil, err
+			}
+			for _, img := range page {
+				evict(img, ReasonAge)
+				afterID = img.ID
+			}
+			if len(page) < pageSize {
+				break
+			}
+		}
+	}
+
+	overLimit := func() string {
+		switch {
+		case j.cfg.MaxImages > 0 && count > j.cfg.MaxImages:
+			return ReasonCount
+		case j.cfg.MaxBytes > 0 && totalBytes > j.cfg.MaxBytes:
+			return ReasonBytes
+		default:
+			return ""
+		}
+	}
+
+	for offset := 0; overLimit() != ""; offset += pageSize {
+		page, err := j.store.EvictionCandidates(ctx, j.cfg.Policy, offset, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, img := range page {
+			reason := overLimit()
+			if reason == "" {
+				break
+			}
+			if !seen[img.ID] {
+				evict(img, reason)
+			}
+		}
+		if len(page) < pageSize {
+			break
+		}
+	}
+
+	return planned, nil
+}
+
+func (j *Janitor) log(report *Report) {
+	if len(report.Evicted) == 0 && len(report.Errors) == 0 {
+		j.logger.Debug("janitor run found nothing to evict", "duration", report.Duration)
+		return
+	}
+
+	ids := make([]uint, 0, len(report.Evicted))
+	for _, eviction := range report.Evicted {
+		ids = append(ids, eviction.ID)
+	}
+
+	j.logger.Info("janitor run finished",
+		"dry_run", report.DryRun,
+		"evicted", len(report.Evicted),
+		"freed_bytes", report.FreedBytes(),
+		"image_ids", ids,
+		"images_after", report.ImagesAfter,
+		"bytes_after", report.BytesAfter,
+		"errors", len(report.Errors),
+		"duration", report.Duration,
+	)
+	for _, err := range report.Errors {
+		j.logger.Warn("janitor eviction failed", "error", err)
+	}
+}
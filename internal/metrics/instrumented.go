@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/IavilaGw/cat-api/internal/models"
+	"github.com/IavilaGw/cat-api/internal/services"
+	"github.com/IavilaGw/cat-api/pkg/client"
+)
+
+type instrumentedClient struct {
+	services.CataasClientInterface
+	metrics *Metrics
+}
+
+// InstrumentClient records latency and errors of every CATAAS fetch.
+func (m *Metrics) InstrumentClient(next services.CataasClientInterface) services.CataasClientInterface {
+	return &instrumentedClient{CataasClientInterface: next, metrics: m}
+}
+
+func (c *instrumentedClient) GetRandomCat(ctx context.Context, opts services.CatOptions) (*services.CatImageResponse, error) {
+	start := time.Now()
+	resp, err := c.CataasClientInterface.GetRandomCat(ctx, opts)
+
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+		c.metrics.upstreamErrors.WithLabelValues(upstreamStatus(err)).Inc()
+	}
+	c.metrics.upstreamDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+
+	return resp, err
+}
+
+func upstreamStatus(err error) string {
+	var upstreamErr *client.UpstreamError
+	if errors.As(err, &upstreamErr) && upstreamErr.StatusCode != 0 {
+		return strconv.Itoa(upstreamErr.StatusCode)
+	}
+	return "network"
+}
+
+type instrumentedRepository struct {
+	services.CatRepositoryInterface
+	metrics *Metrics
+}
+
+// InstrumentRepository counts dedup hits and misses on Save.
+func (m *Metrics) InstrumentRepository(next services.CatRepositoryInterface) services.CatRepositoryInterface {
+	return &instrumentedRepository{CatRepositoryInterface: next, metrics: m}
+}
+
+func (r *instrumentedRepository) Save(ctx context.Context, imageData []byte, contentType string, variant models.CatVariant) (*models.CatImage, bool, error) {
+	catImage, created, err := r.CatRepositoryInterface.Save(ctx, imageData, contentType, variant)
+	if err == nil {
+		if created {
+			r.metrics.dedup.WithLabelValues("miss").Inc()
+		} else {
+			r.metrics.dedup.WithLabelValues("hit").Inc()
+		}
+	}
+	return catImage, created, err
+}
@@ -0,0 +1,178 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/IavilaGw/cat-api/internal/janitor"
+	"github.com/IavilaGw/cat-api/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "catapi"
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests     *prometheus.CounterVec
+	httpDuration     *prometheus.HistogramVec
+	upstreamDuration *prometheus.HistogramVec
+	upstreamErrors   *prometheus.CounterVec
+	dedup            *prometheus.CounterVec
+	janitorRuns      *prometheus.CounterVec
+	janitorEvicted   *prometheus.CounterVec
+	janitorFreed     *prometheus.CounterVec
+	janitorLastRun   prometheus.Gauge
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route, method and status.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		upstreamDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "cataas_request_duration_seconds",
+			Help:      "Latency of CATAAS fetches, including retries.",
+			Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		}, []string{"outcome"}),
+		upstreamErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cataas_errors_total",
+			Help:      "Failed CATAAS fetches by upstream status (\"network\" when there was no response).",
+		}, []string{"status"}),
+		dedup: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "image_dedup_total",
+			Help:      "Saved images by dedup result (hit: already stored, miss: new image).",
+		}, []string{"result"}),
+		janitorRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "janitor_runs_total",
+			Help:      "Retention janitor runs by outcome (ok or error).",
+		}, []string{"outcome"}),
+		janitorEvicted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "janitor_evicted_images_total",
+			Help:      "Images evicted by the retention janitor by reason (age, count, bytes); dry_run=\"true\" counts images that would have been evicted.",
+		}, []string{"reason", "dry_run"}),
+		janitorFreed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "janitor_evicted_bytes_total",
+			Help:      "Bytes freed by the retention janitor.",
+		}, []string{"dry_run"}),
+		janitorLastRun: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "janitor_last_run_timestamp_seconds",
+			Help:      "Unix time the last retention janitor run finished.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.upstreamDuration,
+		m.upstreamErrors,
+		m.dedup,
+		m.janitorRuns,
+		m.janitorEvicted,
+		m.janitorFreed,
+		m.janitorLastRun,
+	)
+
+	return m
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware records request count and latency. Routes are labelled by their
+// pattern (/api/image/:id), never the raw path, to keep cardinality bounded.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		m.httpRequests.WithLabelValues(route, c.Request.Method, status).Inc()
+		m.httpDuration.WithLabelValues(route, c.Request.Method, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveJanitorRun is meant to be passed to janitor.WithReporter.
+func (m *Metrics) ObserveJanitorRun(report *janitor.Report) {
+	outcome := "ok"
+	if len(report.Errors) > 0 {
+		outcome = "error"
+	}
+	m.janitorRuns.WithLabelValues(outcome).Inc()
+
+	dryRun := strconv.FormatBool(report.DryRun)
+	for _, eviction := range report.Evicted {
+		m.janitorEvicted.WithLabelValues(eviction.Reason, dryRun).Inc()
+	}
+	m.janitorFreed.WithLabelValues(dryRun).Add(float64(report.FreedBytes()))
+	m.janitorLastRun.Set(float64(report.Started.Add(report.Duration).Unix()))
+}
+
+func (m *Metrics) RegisterDBStats(db *sql.DB, dbName string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, dbName))
+}
+
+// RegisterImageStats exposes the stored image count and size, queried on
+// every scrape.
+func (m *Metrics) RegisterImageStats(stats func(ctx context.Context) (*models.CatImageStats, error)) {
+	m.registry.MustRegister(&imageStatsCollector{stats: stats})
+}
+
+var (
+	storedImagesDesc = prometheus.NewDesc(namespace+"_stored_images", "Number of stored images.", nil, nil)
+	storedBytesDesc  = prometheus.NewDesc(namespace+"_stored_image_bytes", "Total size of stored images in bytes.", nil, nil)
+)
+
+type imageStatsCollector struct {
+	stats func(ctx context.Context) (*models.CatImageStats, error)
+}
+
+func (c *imageStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- storedImagesDesc
+	ch <- storedBytesDesc
+}
+
+func (c *imageStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stats, err := c.stats(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(storedImagesDesc, err)
+		ch <- prometheus.NewInvalidMetric(storedBytesDesc, err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(storedImagesDesc, prometheus.GaugeValue, float64(stats.TotalImages))
+	ch <- prometheus.MustNewConstMetric(storedBytesDesc, prometheus.GaugeValue, float64(stats.TotalSize))
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/IavilaGw/cat-api/internal/models"
+	"github.com/IavilaGw/cat-api/internal/services"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+const apiKeyContextKey = "api_key"
+
+type Authenticator interface {
+	Authenticate(ctx context.Context, plaintext string) (*models.APIKey, error)
+}
+
+// APIKeyAuth guards routes with API keys. Each route declares the scope it
+// needs through Require.
+type APIKeyAuth struct {
+	authenticator Authenticator
+	logger        *slog.Logger
+	disabled      bool
+	publicRead    bool
+}
+
+func NewAPIKeyAuth(authenticator Authenticator, logger *slog.Logger) *APIKeyAuth {
+	return &APIKeyAuth{authenticator: authenticator, logger: logger}
+}
+
+// WithDisabled turns every Require into a no-op.
+func (a *APIKeyAuth) WithDisabled(disabled bool) *APIKeyAuth {
+	a.disabled = disabled
+	return a
+}
+
+// WithPublicRead lets requests without a key through routes that only need
+// the read scope. A key that is sent anyway is still checked.
+func (a *APIKeyAuth) WithPublicRead(publicRead bool) *APIKeyAuth {
+	a.publicRead = publicRead
+	return a
+}
+
+func (a *APIKeyAuth) Require(scope models.APIScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.disabled {
+			c.Next()
+			return
+		}
+
+		plaintext := apiKeyFromRequest(c.Request)
+		if plaintext == "" {
+			if scope == models.ScopeRead && a.publicRead {
+				c.Next()
+				return
+			}
+			unauthorized(c, "API key required")
+			return
+		}
+
+		key, err := a.authenticator.Authenticate(c.Request.Context(), plaintext)
+		if errors.Is(err, services.ErrInvalidAPIKey) {
+			unauthorized(c, "Invalid API key")
+			return
+		}
+		if err != nil {
+			a.logger.ErrorContext(c.Request.Context(), "failed to check api key", "error", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to check API key",
+			})
+			return
+		}
+
+		c.Set(apiKeyContextKey, key)
+		if !key.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":   "Insufficient scope",
+				"message": "this API key lacks the " + string(scope) + " scope",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireIf is Require for the requests where when returns true; the rest
+// pass through untouched.
+func (a *APIKeyAuth) RequireIf(scope models.APIScope, when func(*gin.Context) bool) gin.HandlerFunc {
+	require := a.Require(scope)
+	return func(c *gin.Context) {
+		if when(c) {
+			require(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// APIKeyFrom returns the key that authenticated the request, if any.
+func APIKeyFrom(c *gin.Context) (*models.APIKey, bool) {
+	value, ok := c.Get(apiKeyContextKey)
+	if !ok {
+		return nil, false
+	}
+	key, ok := value.(*models.APIKey)
+	return key, ok
+}
+
+// apiKeyFromRequest accepts "Authorization: Bearer <key>" or X-API-Key.
+func apiKeyFromRequest(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		scheme, token, ok := strings.Cut(auth, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	return strings.TrimSpace(r.Header.Get(APIKeyHeader))
+}
+
+func unauthorized(c *gin.Context, message string) {
+	c.Header("WWW-Authenticate", `Bearer realm="cat-api"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+		"error": message,
+	})
+}
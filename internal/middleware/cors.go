@@ -0,0 +1,212 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSPolicy says which browser origins may call the API and what they may
+// send and read.
+type CORSPolicy struct {
+	// AllowedOrigins holds exact origins ("https://cats.example.com"),
+	// glob patterns ("https://*.example.com") or "*" for any origin.
+	AllowedOrigins   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// CORS answers preflight requests and decorates actual requests. The allowed
+// methods for a path are the ones registered on the router for it.
+type CORS struct {
+	policy       CORSPolicy
+	anyOrigin    bool
+	allowHeaders string
+	exposeHeader string
+	maxAge       string
+
+	once   sync.Once
+	routes []corsRoute
+}
+
+type corsRoute struct {
+	segments []string
+	methods  []string
+}
+
+func NewCORS(policy CORSPolicy) (*CORS, error) {
+	c := &CORS{
+		policy:       policy,
+		allowHeaders: strings.Join(policy.AllowedHeaders, ", "),
+		exposeHeader: strings.Join(policy.ExposedHeaders, ", "),
+		maxAge:       strconv.Itoa(int(policy.MaxAge.Seconds())),
+	}
+
+	for _, origin := range policy.AllowedOrigins {
+		if origin == "*" {
+			c.anyOrigin = true
+			continue
+		}
+		if _, err := path.Match(origin, ""); err != nil {
+			return nil, fmt.Errorf("invalid CORS origin pattern %q: %w", origin, err)
+		}
+	}
+	// Browsers refuse credentials with a wildcard origin, and echoing every
+	// origin instead would let any site act on behalf of the user.
+	if c.anyOrigin && policy.AllowCredentials {
+		return nil, errors.New("CORS credentials cannot be allowed for every origin (\"*\")")
+	}
+
+	return c, nil
+}
+
+// Handler returns the middleware. engine is read on the first request, once
+// every route has been registered.
+func (c *CORS) Handler(engine *gin.Engine) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		c.once.Do(func() { c.routes = collectRoutes(engine.Routes()) })
+
+		origin := ctx.GetHeader("Origin")
+		preflight := ctx.Request.Method == http.MethodOptions && ctx.GetHeader("Access-Control-Request-Method") != ""
+		if !c.anyOrigin {
+			ctx.Writer.Header().Add("Vary", "Origin")
+		}
+
+		if preflight {
+			c.preflight(ctx, origin)
+			return
+		}
+
+		if origin != "" && c.originAllowed(origin) {
+			c.setOriginHeaders(ctx, origin)
+			if c.exposeHeader != "" {
+				ctx.Header("Access-Control-Expose-Headers", c.exposeHeader)
+			}
+		}
+		ctx.Next()
+	}
+}
+
+func (c *CORS) preflight(ctx *gin.Context, origin string) {
+	header := ctx.Writer.Header()
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+
+	methods := c.methodsFor(ctx.Request.URL.Path)
+	if len(methods) == 0 {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if origin == "" || !c.originAllowed(origin) || !slices.Contains(methods, ctx.GetHeader("Access-Control-Request-Method")) {
+		ctx.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	c.setOriginHeaders(ctx, origin)
+	ctx.Header("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if c.allowHeaders != "" {
+		ctx.Header("Access-Control-Allow-Headers", c.allowHeaders)
+	}
+	if c.policy.MaxAge > 0 {
+		ctx.Header("Access-Control-Max-Age", c.maxAge)
+	}
+	ctx.AbortWithStatus(http.StatusNoContent)
+}
+
+func (c *CORS) setOriginHeaders(ctx *gin.Context, origin string) {
+	if c.anyOrigin {
+		ctx.Header("Access-Control-Allow-Origin", "*")
+		return
+	}
+	ctx.Header("Access-Control-Allow-Origin", origin)
+	if c.policy.AllowCredentials {
+		ctx.Header("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *CORS) originAllowed(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+	for _, allowed := range c.policy.AllowedOrigins {
+		if allowed == origin {
+			return true
+		}
+		// path.Match's * stops at "/", so "https://*.example.com" cannot
+		// match "https://evil.com/.example.com".
+		if ok, _ := path.Match(allowed, origin); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// methodsFor returns the methods registered for every route pattern that
+// matches urlPath, or nil when none does.
+func (c *CORS) methodsFor(urlPath string) []string {
+	segments := splitPath(urlPath)
+	var methods []string
+	for _, route := range c.routes {
+		if !matchRoute(route.segments, segments) {
+			continue
+		}
+		for _, method := range route.methods {
+			if !slices.Contains(methods, method) {
+				methods = append(methods, method)
+			}
+		}
+	}
+	slices.Sort(methods)
+	return methods
+}
+
+func collectRoutes(infos gin.RoutesInfo) []corsRoute {
+	byPath := make(map[string]*corsRoute)
+	var routes []*corsRoute
+	for _, info := range infos {
+		route, ok := byPath[info.Path]
+		if !ok {
+			route = &corsRoute{segments: splitPath(info.Path)}
+			byPath[info.Path] = route
+			routes = append(routes, route)
+		}
+		route.methods = append(route.methods, info.Method)
+	}
+
+	result := make([]corsRoute, 0, len(routes))
+	for _, route := range routes {
+		result = append(result, *route)
+	}
+	return result
+}
+
+// matchRoute matches gin patterns: ":name" is one segment, "*name" the rest.
+func matchRoute(pattern, segments []string) bool {
+	for i, part := range pattern {
+		if strings.HasPrefix(part, "*") {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if !strings.HasPrefix(part, ":") && part != segments[i] {
+			return false
+		}
+	}
+	return len(pattern) == len(segments)
+}
+
+func splitPath(p string) []string {
+	return strings.Split(strings.Trim(p, "/"), "/")
+}
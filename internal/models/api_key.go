@@ -0,0 +1,69 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// APIScope is a permission granted to an API key.
+type APIScope string
+
+const (
+	// ScopeRead reads stored images and their metadata.
+	ScopeRead APIScope = "read"
+	// ScopeFetch fetches new images from CATAAS through /api/cat.
+	ScopeFetch APIScope = "fetch"
+	// ScopeWrite uploads, deletes and restores images.
+	ScopeWrite APIScope = "write"
+	// ScopeAdmin purges images and reads /metrics and /janitor, and implies
+	// every other scope.
+	ScopeAdmin APIScope = "admin"
+)
+
+var AllScopes = []APIScope{ScopeRead, ScopeFetch, ScopeWrite, ScopeAdmin}
+
+type APIKey struct {
+	ID     uint   `gorm:"primaryKey" json:"id"`
+	Name   string `gorm:"type:varchar(100);not null" json:"name"`
+	Prefix string `gorm:"type:varchar(16);not null" json:"prefix"`
+	// KeyHash is the hex SHA-256 of the full key; the key itself is never
+	// stored.
+	KeyHash    string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	Scopes     string     `gorm:"type:varchar(100);not null" json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"not null" json:"created_at"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// ScopeList splits the comma-separated Scopes column.
+func (k *APIKey) ScopeList() []APIScope {
+	var scopes []APIScope
+	for _, s := range strings.Split(k.Scopes, ",") {
+		if s != "" {
+			scopes = append(scopes, APIScope(s))
+		}
+	}
+	return scopes
+}
+
+func (k *APIKey) HasScope(scope APIScope) bool {
+	for _, s := range k.ScopeList() {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Usable reports whether the key is neither revoked nor expired at now.
+func (k *APIKey) Usable(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
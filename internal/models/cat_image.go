@@ -8,16 +8,27 @@ import (
 
 type CatImage struct {
 	ID             uint           `gorm:"primaryKey" json:"id"`
-	ImageData      []byte         `gorm:"type:bytea;not null" json:"-"`
 	ImageHash      string         `gorm:"type:varchar(64);uniqueIndex;not null" json:"image_hash"`
+	StorageKey     string         `gorm:"type:varchar(255);not null;default:''" json:"-"`
 	ContentType    string         `gorm:"type:varchar(50);not null" json:"content_type"`
 	Size           int64          `gorm:"not null" json:"size"`
 	CreatedAt      time.Time      `gorm:"not null" json:"created_at"`
 	LastAccessedAt time.Time      `gorm:"not null" json:"last_accessed_at"`
 	AccessCount    int            `gorm:"default:0" json:"access_count"`
+	Variant        CatVariant     `gorm:"embedded;embeddedPrefix:variant_" json:"variant"`
 	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// CatVariant records the CATAAS options that produced a stored image.
+type CatVariant struct {
+	Tag    string `gorm:"type:varchar(100);index" json:"tag,omitempty"`
+	Says   string `gorm:"type:varchar(255)" json:"says,omitempty"`
+	Filter string `gorm:"type:varchar(20)" json:"filter,omitempty"`
+	Width  int    `gorm:"default:0" json:"width,omitempty"`
+	Height int    `gorm:"default:0" json:"height,omitempty"`
+	Type   string `gorm:"type:varchar(20)" json:"type,omitempty"`
+}
+
 func (CatImage) TableName() string {
 	return "cat_images"
 }
@@ -39,4 +50,5 @@ type CatImageStats struct {
 	TotalSize       int64 `json:"total_size_bytes"`
 	MostAccessedID  uint  `json:"most_accessed_id,omitempty"`
 	MostAccessCount int   `json:"most_access_count,omitempty"`
+	FallbackServed  int64 `json:"fallback_served"`
 }
\ No newline at end of file
@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// ImageSort is a column GET /api/images can be ordered by.
+type ImageSort string
+
+const (
+	SortCreatedAt      ImageSort = "created_at"
+	SortAccessCount    ImageSort = "access_count"
+	SortSize           ImageSort = "size"
+	SortLastAccessedAt ImageSort = "last_accessed_at"
+)
+
+// IsTime reports whether the sort column holds timestamps rather than
+// integers, which decides which cursor field carries its value.
+func (s ImageSort) IsTime() bool {
+	return s == SortCreatedAt || s == SortLastAccessedAt
+}
+
+// ImageCursor is the position of the last image of a page: its sort value
+// plus the ID, which breaks ties between equal values.
+type ImageCursor struct {
+	ID   uint
+	Time time.Time
+	Num  int64
+}
+
+// ImageListQuery filters and orders a page of stored images. Zero times
+// leave that side of the date range open.
+type ImageListQuery struct {
+	Sort          ImageSort
+	Descending    bool
+	ContentType   string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	After         *ImageCursor
+	Limit         int
+}
+
+// CursorFor returns the cursor pointing at img for the given sort.
+func CursorFor(img *CatImage, sort ImageSort) ImageCursor {
+	cursor := ImageCursor{ID: img.ID}
+	switch sort {
+	case SortCreatedAt:
+		cursor.Time = img.CreatedAt
+	case SortLastAccessedAt:
+		cursor.Time = img.LastAccessedAt
+	case SortAccessCount:
+		cursor.Num = int64(img.AccessCount)
+	case SortSize:
+		cursor.Num = img.Size
+	}
+	return cursor
+}
+
+// EvictionPolicy orders images for the retention janitor, least valuable
+// first.
+type EvictionPolicy string
+
+const (
+	// EvictLRU evicts the least recently accessed images first.
+	EvictLRU EvictionPolicy = "lru"
+	// EvictLFU evicts the least often accessed images first, oldest access
+	// breaking ties.
+	EvictLFU EvictionPolicy = "lfu"
+)
@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// takeTokenSQL refills the bucket and takes a token in one statement, using
+// the database clock so every replica agrees. When the bucket is empty the
+// WHERE clause skips the update and no row comes back.
+const takeTokenSQL = `
+INSERT INTO rate_limit_buckets AS b (key, tokens, updated_at, expires_at)
+VALUES (?, ?::float8 - 1, now(), now() + make_interval(secs => ?))
+ON CONFLICT (key) DO UPDATE SET
+	tokens = LEAST(?::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * ?::float8) - 1,
+	updated_at = now(),
+	expires_at = now() + make_interval(secs => ?)
+WHERE LEAST(?::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * ?::float8) >= 1
+RETURNING tokens`
+
+const peekTokensSQL = `
+SELECT LEAST(?::float8, tokens + EXTRACT(EPOCH FROM now() - updated_at)::float8 * ?::float8)
+FROM rate_limit_buckets WHERE key = ?`
+
+// PostgresStore shares buckets between replicas through the
+// rate_limit_buckets table.
+type PostgresStore struct {
+	db *gorm.DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Decision, error) {
+	s.sweep(ctx)
+
+	burst := float64(limit.Burst)
+	window := limit.Window().Seconds()
+
+	var taken []float64
+	err := s.db.WithContext(ctx).Raw(takeTokenSQL,
+		key, burst, window,
+		burst, limit.Rate, window,
+		burst, limit.Rate,
+	).Scan(&taken).Error
+	if err != nil {
+		return Decision{}, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+	if len(taken) == 1 {
+		return decide(true, taken[0], limit), nil
+	}
+
+	var tokens float64
+	if err := s.db.WithContext(ctx).Raw(peekTokensSQL, burst, limit.Rate, key).Row().Scan(&tokens); err != nil {
+		return Decision{}, fmt.Errorf("failed to read rate limit bucket: %w", err)
+	}
+	return decide(false, tokens, limit), nil
+}
+
+// sweep drops buckets that have been full for a while, at most once per
+// sweepInterval per process.
+func (s *PostgresStore) sweep(ctx context.Context) {
+	s.mu.Lock()
+	if time.Since(s.lastSweep) < sweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = time.Now()
+	s.mu.Unlock()
+
+	s.db.WithContext(ctx).Exec("DELETE FROM rate_limit_buckets WHERE expires_at < now()")
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/IavilaGw/cat-api/internal/config"
+)
+
+// Limit is a token bucket: Burst tokens at most, refilled at Rate tokens per
+// second. Every request takes one token.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Enabled reports whether the limit restricts anything.
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// Window is how long an empty bucket takes to refill completely.
+func (l Limit) Window() time.Duration {
+	return secondsToDuration(float64(l.Burst) / l.Rate)
+}
+
+// ParseLimit parses a rule such as "30/m" (see config.ParseRate). burst 0
+// defaults to the count. An empty spec or "0" returns a disabled limit.
+func ParseLimit(spec string, burst int) (Limit, error) {
+	count, per, err := config.ParseRate(spec)
+	if err != nil || count == 0 {
+		return Limit{}, err
+	}
+	if burst <= 0 {
+		burst = count
+	}
+	return Limit{Rate: float64(count) / per.Seconds(), Burst: burst}, nil
+}
+
+// Decision is the outcome of taking a token.
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until the next token, set only when the
+	// request was rejected.
+	RetryAfter time.Duration
+	// ResetAfter is how long until the bucket is full again.
+	ResetAfter time.Duration
+}
+
+// Store keeps the buckets. Keys are opaque and already include the route
+// group.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Decision, error)
+}
+
+// refill returns the tokens in a bucket that had tokens left elapsed ago.
+func refill(tokens float64, elapsed time.Duration, limit Limit) float64 {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(float64(limit.Burst), tokens+elapsed.Seconds()*limit.Rate)
+}
+
+// decide builds the decision once the bucket holds tokens, after taking one
+// when allowed.
+func decide(allowed bool, tokens float64, limit Limit) Decision {
+	d := Decision{
+		Allowed:    allowed,
+		Limit:      limit.Burst,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: secondsToDuration((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !allowed {
+		d.RetryAfter = secondsToDuration((1 - tokens) / limit.Rate)
+	}
+	return d
+}
+
+func secondsToDuration(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
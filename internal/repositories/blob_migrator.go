@@ -0,0 +1,134 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/IavilaGw/cat-api/internal/models"
+	"github.com/IavilaGw/cat-api/internal/storage"
+	"gorm.io/gorm"
+)
+
+// BlobMigrator copies image bytes still stored in the legacy image_data
+// column into the blob store. Rows are only cleared after the stored copy
+// has been read back and matches ImageHash, so the run can be interrupted
+// and restarted at any point.
+type BlobMigrator struct {
+	db        *gorm.DB
+	store     storage.BlobStore
+	batchSize int
+}
+
+type BlobMigrationProgress struct {
+	Total    int64
+	Migrated int64
+	Failed   int64
+	LastID   uint
+	// Errors holds the failures of the most recent batch only.
+	Errors []error
+}
+
+type legacyImageRow struct {
+	ID          uint
+	ImageHash   string
+	ContentType string
+	ImageData   []byte
+}
+
+func NewBlobMigrator(db *gorm.DB, store storage.BlobStore, batchSize int) *BlobMigrator {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	return &BlobMigrator{db: db, store: store, batchSize: batchSize}
+}
+
+func (m *BlobMigrator) Pending(ctx context.Context) (int64, error) {
+	if !m.db.Migrator().HasColumn(&models.CatImage{}, "image_data") {
+		return 0, nil
+	}
+
+	var count int64
+	if err := m.db.WithContext(ctx).Raw("SELECT COUNT(*) FROM cat_images WHERE image_data IS NOT NULL").Scan(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count pending images: %w", err)
+	}
+	return count, nil
+}
+
+// Run migrates every pending row, calling report after each batch. It stops
+// between rows when ctx is cancelled and returns ctx.Err().
+func (m *BlobMigrator) Run(ctx context.Context, report func(BlobMigrationProgress)) (BlobMigrationProgress, error) {
+	var progress BlobMigrationProgress
+
+	total, err := m.Pending(ctx)
+	if err != nil {
+		return progress, err
+	}
+	progress.Total = total
+	if total == 0 {
+		return progress, nil
+	}
+
+	for {
+		var rows []legacyImageRow
+		err := m.db.WithContext(ctx).Raw(
+			"SELECT id, image_hash, content_type, image_data FROM cat_images WHERE image_data IS NOT NULL AND id > ? ORDER BY id LIMIT ?",
+			progress.LastID, m.batchSize,
+		).Scan(&rows).Error
+		if err != nil {
+			return progress, fmt.Errorf("failed to read batch: %w", err)
+		}
+		if len(rows) == 0 {
+			return progress, nil
+		}
+
+		progress.Errors = nil
+		for _, row := range rows {
+			if err := ctx.Err(); err != nil {
+				return progress, err
+			}
+
+			if err := m.migrateRow(ctx, row); err != nil {
+				if ctx.Err() != nil {
+					return progress, ctx.Err()
+				}
+				progress.Failed++
+				progress.Errors = append(progress.Errors, err)
+			} else {
+				progress.Migrated++
+			}
+			progress.LastID = row.ID
+		}
+
+		if report != nil {
+			report(progress)
+		}
+	}
+}
+
+func (m *BlobMigrator) migrateRow(ctx context.Context, row legacyImageRow) error {
+	if calculateHash(row.ImageData) != row.ImageHash {
+		return fmt.Errorf("image %d: stored bytes do not match image_hash", row.ID)
+	}
+
+	key := storage.KeyForHash(row.ImageHash)
+	if err := m.store.Put(ctx, key, row.ImageData, row.ContentType); err != nil {
+		return fmt.Errorf("image %d: %w", row.ID, err)
+	}
+
+	stored, err := m.store.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("image %d: failed to read back blob: %w", row.ID, err)
+	}
+	if calculateHash(stored) != row.ImageHash {
+		return fmt.Errorf("image %d: blob store copy does not match image_hash", row.ID)
+	}
+
+	err = m.db.WithContext(ctx).Exec(
+		"UPDATE cat_images SET storage_key = ?, image_data = NULL WHERE id = ?",
+		key, row.ID,
+	).Error
+	if err != nil {
+		return fmt.Errorf("image %d: failed to clear image_data: %w", row.ID, err)
+	}
+	return nil
+}
@@ -1,83 +1,444 @@
 package repositories
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"time"
 	"github.com/IavilaGw/cat-api/internal/models"
+	"github.com/IavilaGw/cat-api/internal/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
+var tracer = otel.Tracer("github.com/IavilaGw/cat-api/internal/repositories")
+
+var (
+	ErrNotFound = errors.New("image not found")
+	// ErrImageDeleted is returned by Save when the image matches a
+	// soft-deleted one and the policy keeps it hidden.
+	ErrImageDeleted = errors.New("image was deleted")
+)
+
+// DeletedImagePolicy decides what Save does when it receives the bytes of a
+// soft-deleted image.
+type DeletedImagePolicy string
+
+const (
+	// DeletedImageHide keeps the image deleted and makes Save fail with
+	// ErrImageDeleted.
+	DeletedImageHide DeletedImagePolicy = "hide"
+	// DeletedImageRestore brings the image back as if it was never deleted.
+	DeletedImageRestore DeletedImagePolicy = "restore"
+)
+
+func ParseDeletedImagePolicy(value string) (DeletedImagePolicy, error) {
+	switch policy := DeletedImagePolicy(value); policy {
+	case DeletedImageHide, DeletedImageRestore:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown deleted image policy %q (want hide or restore)", value)
+	}
+}
+
 type CatRepository struct {
-	db *gorm.DB
+	db            *gorm.DB
+	store         storage.BlobStore
+	deletedPolicy DeletedImagePolicy
+}
+
+func NewCatRepository(db *gorm.DB, store storage.BlobStore) *CatRepository {
+	return &CatRepository{db: db, store: store, deletedPolicy: DeletedImageHide}
 }
 
-func NewCatRepository(db *gorm.DB) *CatRepository {
-	return &CatRepository{db: db}
+func (r *CatRepository) WithDeletedImagePolicy(policy DeletedImagePolicy) *CatRepository {
+	r.deletedPolicy = policy
+	return r
+}
+
+// imageColumns are the models.CatImage columns. Every query that reads a
+// CatImage selects them instead of *, so the legacy image_data column, still
+// present on databases not yet moved by migrate-blobs, never travels back
+// with the row.
+const imageColumns = `id, image_hash, storage_key, content_type, size, created_at, last_accessed_at, access_count,
+	variant_tag, variant_says, variant_filter, variant_width, variant_height, variant_type, deleted_at`
+
+// upsertImageSQL inserts a new image or, when the hash is already stored,
+// counts one more access in the same statement so concurrent saves of the
+// same bytes neither hit the unique index nor lose increments. The %s slot
+// takes the WHERE clause that keeps soft-deleted rows hidden.
+const upsertImageSQL = `
+INSERT INTO cat_images (
+	image_hash, storage_key, content_type, size, created_at, last_accessed_at, access_count,
+	variant_tag, variant_says, variant_filter, variant_width, variant_height, variant_type
+) VALUES (?, ?, ?, ?, now(), now(), 1, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (image_hash) DO UPDATE SET
+	access_count = cat_images.access_count + 1,
+	last_accessed_at = now(),
+	deleted_at = NULL
+%s
+RETURNING ` + imageColumns + `, (xmax = 0) AS inserted`
+
+type upsertedImage struct {
+	models.CatImage
+	Inserted bool
 }
 
-func (r *CatRepository) Save(imageData []byte, contentType string) (*models.CatImage, error) {
+func (r *CatRepository) Save(ctx context.Context, imageData []byte, contentType string, variant models.CatVariant) (*models.CatImage, bool, error) {
 	hash := calculateHash(imageData)
+	key := storage.KeyForHash(hash)
+
+	// Soft-deleted rows still own their hash (the unique index covers them),
+	// so they count as known too.
+	var known int64
+	if err := r.db.WithContext(ctx).Unscoped().Model(&models.CatImage{}).Where("image_hash = ?", hash).Count(&known).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to look up image: %w", err)
+	}
 
-	var existing models.CatImage
-	if err := r.db.Where("image_hash = ?", hash).First(&existing).Error; err == nil {
-		existing.UpdateLastAccessed()
-		if err := r.db.Save(&existing).Error; err != nil {
-			return nil, fmt.Errorf("failed to update image: %w", err)
+	// Blobs are content-addressed, so a concurrent save writing the same key
+	// is harmless; skipping the write for known images only saves work.
+	if known == 0 {
+		if err := r.putBlob(ctx, key, imageData, contentType); err != nil {
+			return nil, false, err
 		}
-		return &existing, nil
 	}
 
-	catImage := &models.CatImage{
-		ImageData:   imageData,
-		ImageHash:   hash,
-		ContentType: contentType,
-		Size:        int64(len(imageData)),
-		AccessCount: 1,
+	onConflict := ""
+	if r.deletedPolicy != DeletedImageRestore {
+		onConflict = "WHERE cat_images.deleted_at IS NULL"
+	}
+
+	var rows []upsertedImage
+	err := r.db.WithContext(ctx).Raw(fmt.Sprintf(upsertImageSQL, onConflict),
+		hash, key, contentType, int64(len(imageData)),
+		variant.Tag, variant.Says, variant.Filter, variant.Width, variant.Height, variant.Type,
+	).Scan(&rows).Error
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to save image: %w", err)
+	}
+
+	// The conflict update was skipped: the image is soft-deleted and stays
+	// hidden.
+	if len(rows) == 0 {
+		var existing models.CatImage
+		if err := r.db.WithContext(ctx).Unscoped().Select(imageColumns).Where("image_hash = ?", hash).First(&existing).Error; err != nil {
+			return nil, false, fmt.Errorf("failed to find deleted image: %w", err)
+		}
+		return &existing, false, ErrImageDeleted
 	}
 
-	if err := r.db.Create(catImage).Error; err != nil {
-		return nil, fmt.Errorf("failed to save image: %w", err)
+	saved := rows[0]
+	// The image was purged between the lookup and the insert, which left the
+	// new row without bytes.
+	if saved.Inserted && known > 0 {
+		if err := r.putBlob(ctx, key, imageData, contentType); err != nil {
+			return nil, false, err
+		}
 	}
 
-	return catImage, nil
+	return &saved.CatImage, saved.Inserted, nil
 }
 
-func (r *CatRepository) FindByID(id uint) (*models.CatImage, error) {
+func (r *CatRepository) putBlob(ctx context.Context, key string, data []byte, contentType string) error {
+	ctx, span := tracer.Start(ctx, "BlobStore.Put", trace.WithAttributes(
+		attribute.String("blob.key", key),
+		attribute.Int("blob.size_bytes", len(data)),
+	))
+	defer span.End()
+
+	if err := r.store.Put(ctx, key, data, contentType); err != nil {
+		return fmt.Errorf("failed to store image: %w", err)
+	}
+	return nil
+}
+
+func (r *CatRepository) FindByID(ctx context.Context, id uint) (*models.CatImage, error) {
 	var catImage models.CatImage
-	if err := r.db.First(&catImage, id).Error; err != nil {
+	if err := r.db.WithContext(ctx).Select(imageColumns).First(&catImage, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("image not found")
+			return nil, ErrNotFound
 		}
 		return nil, fmt.Errorf("failed to find image: %w", err)
 	}
 
-	catImage.UpdateLastAccessed()
-	if err := r.db.Save(&catImage).Error; err != nil {
-		return nil, fmt.Errorf("failed to update access: %w", err)
+	return &catImage, nil
+}
+
+func (r *CatRepository) FindByHash(ctx context.Context, hash string) (*models.CatImage, error) {
+	var catImage models.CatImage
+	if err := r.db.WithContext(ctx).Select(imageColumns).Where("image_hash = ?", hash).First(&catImage).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to find image: %w", err)
 	}
 
 	return &catImage, nil
 }
 
-func (r *CatRepository) CountUnique() (int64, error) {
+// SoftDelete hides the image from every query; its bytes are kept so it can
+// be restored.
+func (r *CatRepository) SoftDelete(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Delete(&models.CatImage{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete image: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Restore undoes SoftDelete. Restoring an image that is not deleted is a
+// no-op.
+func (r *CatRepository) Restore(ctx context.Context, id uint) (*models.CatImage, error) {
+	result := r.db.WithContext(ctx).Unscoped().Model(&models.CatImage{}).
+		Where("id = ?", id).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to restore image: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+	return r.FindByID(ctx, id)
+}
+
+// Purge removes the row, deleted or not, and then its bytes. The row goes
+// first: a failure in between leaves an unreferenced blob rather than a row
+// pointing at missing data.
+func (r *CatRepository) Purge(ctx context.Context, id uint) error {
+	var catImage models.CatImage
+	if err := r.db.WithContext(ctx).Unscoped().Select(imageColumns).First(&catImage, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to find image: %w", err)
+	}
+
+	if err := r.db.WithContext(ctx).Unscoped().Delete(&catImage).Error; err != nil {
+		return fmt.Errorf("failed to purge image: %w", err)
+	}
+
+	if catImage.StorageKey != "" {
+		if err := r.store.Delete(ctx, catImage.StorageKey); err != nil {
+			return fmt.Errorf("failed to delete image data: %w", err)
+		}
+	}
+	return nil
+}
+
+// RetentionTotals counts every stored image, soft-deleted ones included
+// since their bytes are still kept.
+func (r *CatRepository) RetentionTotals(ctx context.Context) (count int64, totalBytes int64, err error) {
+	var totals struct {
+		Count int64
+		Bytes sql.NullInt64
+	}
+	err = r.db.WithContext(ctx).Unscoped().Model(&models.CatImage{}).
+		Select("COUNT(*) AS count, SUM(size) AS bytes").
+		Scan(&totals).Error
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to compute totals: %w", err)
+	}
+	return totals.Count, totals.Bytes.Int64, nil
+}
+
+// ExpiredImages pages, by ID, through images not accessed since before.
+func (r *CatRepository) ExpiredImages(ctx context.Context, before time.Time, afterID uint, limit int) ([]models.CatImage, error) {
+	var images []models.CatImage
+	err := r.db.WithContext(ctx).Unscoped().Select(imageColumns).
+		Where("last_accessed_at < ? AND id > ?", before, afterID).
+		Order("id").
+		Limit(limit).
+		Find(&images).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find expired images: %w", err)
+	}
+	return images, nil
+}
+
+// EvictionCandidates returns images in eviction order: soft-deleted ones
+// first, then by policy.
+func (r *CatRepository) EvictionCandidates(ctx context.Context, policy models.EvictionPolicy, offset, limit int) ([]models.CatImage, error) {
+	order := "deleted_at IS NULL, last_accessed_at, id"
+	if policy == models.EvictLFU {
+		order = "deleted_at IS NULL, access_count, last_accessed_at, id"
+	}
+
+	var images []models.CatImage
+	err := r.db.WithContext(ctx).Unscoped().Select(imageColumns).
+		Order(order).
+		Offset(offset).
+		Limit(limit).
+		Find(&images).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find eviction candidates: %w", err)
+	}
+	return images, nil
+}
+
+// RecordAccess bumps the access counter in the database and mirrors the
+// change on catImage.
+func (r *CatRepository) RecordAccess(ctx context.Context, catImage *models.CatImage) error {
+	now := time.Now()
+	err := r.db.WithContext(ctx).Model(&models.CatImage{}).
+		Where("id = ?", catImage.ID).
+		Updates(map[string]interface{}{
+			"access_count":     gorm.Expr("access_count + 1"),
+			"last_accessed_at": now,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update access: %w", err)
+	}
+
+	catImage.AccessCount++
+	catImage.LastAccessedAt = now
+	return nil
+}
+
+// FindRandom picks a stored image of exactly variant. Empty fields match
+// empty columns, so a plain request never gets a captioned or filtered image.
+func (r *CatRepository) FindRandom(ctx context.Context, variant models.CatVariant) (*models.CatImage, error) {
+	query := r.db.WithContext(ctx).Select(imageColumns).Where(map[string]interface{}{
+		"variant_tag":    variant.Tag,
+		"variant_says":   variant.Says,
+		"variant_filter": variant.Filter,
+		"variant_width":  variant.Width,
+		"variant_height": variant.Height,
+		"variant_type":   variant.Type,
+	})
+
+	var catImage models.CatImage
+	if err := query.Order("random()").First(&catImage).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to find image: %w", err)
+	}
+
+	return &catImage, nil
+}
+
+// LoadData returns the image bytes. Rows written before the blob store was
+// introduced have no storage key and still hold their bytes in image_data.
+func (r *CatRepository) LoadData(ctx context.Context, catImage *models.CatImage) ([]byte, error) {
+	if catImage.StorageKey == "" {
+		var data []byte
+		if err := r.db.WithContext(ctx).Raw("SELECT image_data FROM cat_images WHERE id = ?", catImage.ID).Row().Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to load image data: %w", err)
+		}
+		if data == nil {
+			return nil, fmt.Errorf("image data missing")
+		}
+		return data, nil
+	}
+
+	data, err := r.store.Get(ctx, catImage.StorageKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load image data: %w", err)
+	}
+	return data, nil
+}
+
+var listSortColumns = map[models.ImageSort]string{
+	models.SortCreatedAt:      "created_at",
+	models.SortAccessCount:    "access_count",
+	models.SortSize:           "size",
+	models.SortLastAccessedAt: "last_accessed_at",
+}
+
+// List returns one page of images using keyset pagination on (sort column,
+// id), so deep pages cost the same as the first one.
+func (r *CatRepository) List(ctx context.Context, q models.ImageListQuery) ([]models.CatImage, error) {
+	column, ok := listSortColumns[q.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort column: %q", q.Sort)
+	}
+
+	direction, comparison := "ASC", ">"
+	if q.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	query := r.db.WithContext(ctx).Model(&models.CatImage{}).Select(imageColumns)
+	if q.ContentType != "" {
+		query = query.Where("content_type = ?", q.ContentType)
+	}
+	if !q.CreatedAfter.IsZero() {
+		query = query.Where("created_at >= ?", q.CreatedAfter)
+	}
+	if !q.CreatedBefore.IsZero() {
+		query = query.Where("created_at < ?", q.CreatedBefore)
+	}
+	if q.After != nil {
+		var value interface{} = q.After.Num
+		if q.Sort.IsTime() {
+			value = q.After.Time
+		}
+		query = query.Where(fmt.Sprintf("(%s, id) %s (?, ?)", column, comparison), value, q.After.ID)
+	}
+
+	var images []models.CatImage
+	err := query.
+		Order(fmt.Sprintf("%s %s, id %s", column, direction, direction)).
+		Limit(q.Limit).
+		Find(&images).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+
+	return images, nil
+}
+
+// OpenData returns a seekable reader over the image bytes, streaming from the
+// blob store when possible.
+func (r *CatRepository) OpenData(ctx context.Context, catImage *models.CatImage) (io.ReadSeekCloser, error) {
+	if catImage.StorageKey == "" {
+		data, err := r.LoadData(ctx, catImage)
+		if err != nil {
+			return nil, err
+		}
+		return nopCloser{bytes.NewReader(data)}, nil
+	}
+
+	reader, err := r.store.Open(ctx, catImage.StorageKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image data: %w", err)
+	}
+	return reader, nil
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error { return nil }
+
+func (r *CatRepository) CountUnique(ctx context.Context) (int64, error) {
 	var count int64
-	if err := r.db.Model(&models.CatImage{}).Count(&count).Error; err != nil {
+	if err := r.db.WithContext(ctx).Model(&models.CatImage{}).Count(&count).Error; err != nil {
 		return 0, fmt.Errorf("failed to count images: %w", err)
 	}
 	return count, nil
 }
 
-func (r *CatRepository) GetStats() (*models.CatImageStats, error) {
+func (r *CatRepository) GetStats(ctx context.Context) (*models.CatImageStats, error) {
 	stats := &models.CatImageStats{}
 
-	if err := r.db.Model(&models.CatImage{}).Count(&stats.TotalImages).Error; err != nil {
+	if err := r.db.WithContext(ctx).Model(&models.CatImage{}).Count(&stats.TotalImages).Error; err != nil {
 		return nil, fmt.Errorf("failed to count: %w", err)
 	}
 
 	var totalSize sql.NullInt64
-	if err := r.db.Model(&models.CatImage{}).Select("SUM(size)").Scan(&totalSize).Error; err != nil {
+	if err := r.db.WithContext(ctx).Model(&models.CatImage{}).Select("SUM(size)").Scan(&totalSize).Error; err != nil {
 		return nil, fmt.Errorf("failed to calculate size: %w", err)
 	}
 	if totalSize.Valid {
@@ -85,7 +446,7 @@ func (r *CatRepository) GetStats() (*models.CatImageStats, error) {
 	}
 
 	var mostAccessed models.CatImage
-	if err := r.db.Order("access_count DESC").First(&mostAccessed).Error; err == nil {
+	if err := r.db.WithContext(ctx).Select(imageColumns).Order("access_count DESC").First(&mostAccessed).Error; err == nil {
 		stats.MostAccessedID = mostAccessed.ID
 		stats.MostAccessCount = mostAccessed.AccessCount
 	}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/IavilaGw/cat-api/internal/models"
+	"github.com/IavilaGw/cat-api/internal/repositories"
+)
+
+const apiKeyPrefix = "cat_"
+
+// lastUsedGranularity limits last_used_at updates to one write per key per
+// minute instead of one per request.
+const lastUsedGranularity = time.Minute
+
+var (
+	ErrAPIKeyNotFound = repositories.ErrAPIKeyNotFound
+	// ErrInvalidAPIKey covers unknown, revoked and expired keys alike so
+	// callers cannot probe which keys exist.
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	ErrInvalidScopes = errors.New("invalid scopes")
+)
+
+type APIKeyService struct {
+	repo   APIKeyRepositoryInterface
+	logger *slog.Logger
+}
+
+func NewAPIKeyService(repo APIKeyRepositoryInterface, logger *slog.Logger) *APIKeyService {
+	return &APIKeyService{repo: repo, logger: logger}
+}
+
+// ParseScopes parses a comma-separated scope list such as "read,fetch".
+func ParseScopes(value string) ([]models.APIScope, error) {
+	var scopes []models.APIScope
+	for _, s := range strings.Split(value, ",") {
+		scope := models.APIScope(strings.TrimSpace(s))
+		if scope == "" {
+			continue
+		}
+		if !validScope(scope) {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidScopes, scope)
+		}
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidScopes)
+	}
+	return scopes, nil
+}
+
+func validScope(scope models.APIScope) bool {
+	for _, s := range models.AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateKey generates a new key and stores its hash. The returned plaintext
+// is the only copy of the key; ttl 0 means it never expires.
+func (s *APIKeyService) CreateKey(ctx context.Context, name string, scopes []models.APIScope, ttl time.Duration) (string, *models.APIKey, error) {
+	if len(scopes) == 0 {
+		return "", nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidScopes)
+	}
+	names := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !validScope(scope) {
+			return "", nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidScopes, scope)
+		}
+		names = append(names, string(scope))
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("failed to generate api key: %w", err)
+	}
+	plaintext := apiKeyPrefix + hex.EncodeToString(secret)
+
+	key := &models.APIKey{
+		Name:      name,
+		Prefix:    plaintext[:len(apiKeyPrefix)+8],
+		KeyHash:   hashAPIKey(plaintext),
+		Scopes:    strings.Join(names, ","),
+		CreatedAt: time.Now(),
+	}
+	if ttl > 0 {
+		expires := key.CreatedAt.Add(ttl)
+		key.ExpiresAt = &expires
+	}
+
+	if err := s.repo.Create(ctx, key); err != nil {
+		return "", nil, err
+	}
+	return plaintext, key, nil
+}
+
+func (s *APIKeyService) ListKeys(ctx context.Context) ([]models.APIKey, error) {
+	return s.repo.List(ctx)
+}
+
+func (s *APIKeyService) RevokeKey(ctx context.Context, id uint) error {
+	return s.repo.Revoke(ctx, id)
+}
+
+// Authenticate resolves a plaintext key to a usable API key.
+func (s *APIKeyService) Authenticate(ctx context.Context, plaintext string) (*models.APIKey, error) {
+	if !strings.HasPrefix(plaintext, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.repo.FindByHash(ctx, hashAPIKey(plaintext))
+	if errors.Is(err, repositories.ErrAPIKeyNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !key.Usable(now) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedGranularity {
+		// A failed bookkeeping write must not reject a valid key.
+		if err := s.repo.TouchLastUsed(ctx, key.ID, now); err != nil {
+			s.logger.WarnContext(ctx, "failed to record api key use", "key_id", key.ID, "error", err)
+		} else {
+			key.LastUsedAt = &now
+		}
+	}
+
+	return key, nil
+}
+
+// Keys are 256 random bits, so a plain SHA-256 is enough; a slow password
+// hash would only add latency to every request.
+func hashAPIKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/IavilaGw/cat-api/internal/models"
+)
+
+const (
+	maxTagLength   = 100
+	maxSaysLength  = 255
+	maxImageLength = 4096
+)
+
+var ErrInvalidOptions = errors.New("invalid cat options")
+
+var tagPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+(,[A-Za-z0-9_-]+)*$`)
+
+var validFilters = map[string]bool{
+	"blur":     true,
+	"mono":     true,
+	"negative": true,
+	"paint":    true,
+	"pixel":    true,
+	"sepia":    true,
+}
+
+var validTypes = map[string]bool{
+	"xsmall": true,
+	"small":  true,
+	"medium": true,
+	"square": true,
+}
+
+// CatOptions selects the CATAAS variant to fetch. The zero value asks for
+// a plain random cat.
+type CatOptions struct {
+	Tag    string
+	Says   string
+	Filter string
+	Width  int
+	Height int
+	Type   string
+}
+
+func (o CatOptions) Validate() error {
+	if o.Tag != "" && (len(o.Tag) > maxTagLength || !tagPattern.MatchString(o.Tag)) {
+		return fmt.Errorf("%w: tag must be comma separated words of letters, digits, '-' or '_' (max %d chars)", ErrInvalidOptions, maxTagLength)
+	}
+	if o.Says != "" && (!utf8.ValidString(o.Says) || utf8.RuneCountInString(o.Says) > maxSaysLength) {
+		return fmt.Errorf("%w: says must be valid text of at most %d characters", ErrInvalidOptions, maxSaysLength)
+	}
+	if o.Filter != "" && !validFilters[o.Filter] {
+		return fmt.Errorf("%w: unknown filter %q", ErrInvalidOptions, o.Filter)
+	}
+	if o.Width < 0 || o.Width > maxImageLength {
+		return fmt.Errorf("%w: width must be between 1 and %d", ErrInvalidOptions, maxImageLength)
+	}
+	if o.Height < 0 || o.Height > maxImageLength {
+		return fmt.Errorf("%w: height must be between 1 and %d", ErrInvalidOptions, maxImageLength)
+	}
+	if o.Type != "" && !validTypes[o.Type] {
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidOptions, o.Type)
+	}
+	return nil
+}
+
+func (o CatOptions) Variant() models.CatVariant {
+	return models.CatVariant{
+		Tag:    o.Tag,
+		Says:   o.Says,
+		Filter: o.Filter,
+		Width:  o.Width,
+		Height: o.Height,
+		Type:   o.Type,
+	}
+}
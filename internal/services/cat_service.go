@@ -1,38 +1,74 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"sync/atomic"
 
 	"github.com/IavilaGw/cat-api/internal/models"
 	"github.com/IavilaGw/cat-api/internal/repositories"
 	"github.com/IavilaGw/cat-api/pkg/client"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	SourceUpstream = "upstream"
+	SourceCache    = "cache"
+)
+
+var (
+	ErrImageNotFound = repositories.ErrNotFound
+	ErrImageDeleted  = repositories.ErrImageDeleted
 )
 
 type CatService struct {
 	repo         CatRepositoryInterface
 	cataasClient CataasClientInterface
+	logger       *slog.Logger
+	uploadLimits UploadLimits
+
+	fallbackServed atomic.Int64
 }
 
-func NewCatService(repo CatRepositoryInterface, cataasClient CataasClientInterface) *CatService {
+func NewCatService(repo CatRepositoryInterface, cataasClient CataasClientInterface, logger *slog.Logger) *CatService {
 	return &CatService{
 		repo:         repo,
 		cataasClient: cataasClient,
+		logger:       logger,
+		uploadLimits: defaultUploadLimits,
 	}
 }
 
-func NewCatServiceWithConcrete(repo *repositories.CatRepository, cataasClient *client.CataasClient) *CatService {
+func NewCatServiceWithConcrete(repo *repositories.CatRepository, cataasClient *client.CataasClient, logger *slog.Logger) *CatService {
 	return &CatService{
 		repo:         repo,
-		cataasClient: &cataasClientAdapter{cataasClient},
+		cataasClient: NewCataasClientAdapter(cataasClient),
+		logger:       logger,
+		uploadLimits: defaultUploadLimits,
 	}
 }
 
+func NewCataasClientAdapter(cataasClient *client.CataasClient) CataasClientInterface {
+	return &cataasClientAdapter{cataasClient}
+}
+
 type cataasClientAdapter struct {
 	client *client.CataasClient
 }
 
-func (a *cataasClientAdapter) GetRandomCat() (*CatImageResponse, error) {
-	resp, err := a.client.GetRandomCat()
+func (a *cataasClientAdapter) GetRandomCat(ctx context.Context, opts CatOptions) (*CatImageResponse, error) {
+	resp, err := a.client.GetRandomCat(ctx, client.CatOptions{
+		Tag:    opts.Tag,
+		Says:   opts.Says,
+		Filter: opts.Filter,
+		Width:  opts.Width,
+		Height: opts.Height,
+		Type:   opts.Type,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -40,48 +76,170 @@ func (a *cataasClientAdapter) GetRandomCat() (*CatImageResponse, error) {
 		Data:        resp.Data,
 		ContentType: resp.ContentType,
 		Size:        resp.Size,
+		Attempts:    resp.Attempts,
 	}, nil
 }
 
-func (a *cataasClientAdapter) HealthCheck() error {
-	return a.client.HealthCheck()
+func (a *cataasClientAdapter) HealthCheck(ctx context.Context) error {
+	return a.client.HealthCheck(ctx)
+}
+
+// FetchResult is a stored image together with the bytes served to the client.
+// Source tells whether it came from CATAAS or from the local cache.
+type FetchResult struct {
+	Image    *models.CatImage
+	Data     []byte
+	Attempts int
+	Source   string
+}
+
+func (s *CatService) FetchAndSaveRandomCat(ctx context.Context, opts CatOptions) (result *FetchResult, err error) {
+	ctx, span := tracer.Start(ctx, "CatService.FetchAndSaveRandomCat", trace.WithAttributes(
+		attribute.String("cat.tag", opts.Tag),
+		attribute.String("cat.filter", opts.Filter),
+		attribute.String("cat.type", opts.Type),
+	))
+	defer func() {
+		if result != nil {
+			span.SetAttributes(
+				attribute.String("cat.source", result.Source),
+				attribute.Int("cat.upstream_attempts", result.Attempts),
+			)
+		}
+		endSpan(span, err)
+	}()
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	fetchCtx, fetchSpan := tracer.Start(ctx, "CatService.fetchUpstream")
+	response, err := s.cataasClient.GetRandomCat(fetchCtx, opts)
+	endSpan(fetchSpan, err)
+	if err != nil {
+		fetchErr := fmt.Errorf("failed to fetch image: %w", err)
+		if ctx.Err() != nil {
+			return nil, fetchErr
+		}
+		return s.serveFromCache(ctx, opts, fetchErr)
+	}
+
+	saveCtx, saveSpan := tracer.Start(ctx, "CatService.saveImage", trace.WithAttributes(
+		attribute.Int("cat.size_bytes", len(response.Data)),
+	))
+	catImage, created, err := s.repo.Save(saveCtx, response.Data, response.ContentType, opts.Variant())
+	saveSpan.SetAttributes(attribute.Bool("cat.dedup_hit", err == nil && !created))
+	endSpan(saveSpan, err)
+	if errors.Is(err, ErrImageDeleted) {
+		// CATAAS handed back an image we deleted on purpose; do not show it.
+		return s.serveFromCache(ctx, opts, fmt.Errorf("failed to save image: %w", err))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save image: %w", err)
+	}
+
+	return &FetchResult{
+		Image:    catImage,
+		Data:     response.Data,
+		Attempts: response.Attempts,
+		Source:   SourceUpstream,
+	}, nil
 }
 
-func (s *CatService) FetchAndSaveRandomCat() (*models.CatImage, []byte, error) {
+// serveFromCache is the degraded mode used when CATAAS is unavailable: a
+// random stored image of the same variant is served instead. If there is
+// none, the original upstream error is returned.
+func (s *CatService) serveFromCache(ctx context.Context, opts CatOptions, fetchErr error) (*FetchResult, error) {
+	ctx, span := tracer.Start(ctx, "CatService.serveFromCache")
+	defer span.End()
+
+	catImage, err := s.repo.FindRandom(ctx, opts.Variant())
+	if err != nil {
+		return nil, fetchErr
+	}
 
-	response, err := s.cataasClient.GetRandomCat()
+	data, err := s.repo.LoadData(ctx, catImage)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to fetch image: %w", err)
+		return nil, errors.Join(fetchErr, err)
 	}
 
-	
+	s.fallbackServed.Add(1)
+	s.logger.WarnContext(ctx, "cataas unavailable, serving cached image", "image_id", catImage.ID, "error", fetchErr)
+
+	var attempts int
+	var upstreamErr *client.UpstreamError
+	if errors.As(fetchErr, &upstreamErr) {
+		attempts = upstreamErr.Attempts
+	}
+
+	return &FetchResult{
+		Image:    catImage,
+		Data:     data,
+		Attempts: attempts,
+		Source:   SourceCache,
+	}, nil
+}
+
+func (s *CatService) GetCatImageByID(ctx context.Context, id uint) (*models.CatImage, error) {
+	return s.repo.FindByID(ctx, id)
+}
 
-	catImage, err := s.repo.Save(response.Data, response.ContentType)
+// GetCatImageByHash looks an image up by its SHA-256, which unlike the ID is
+// the same in every environment.
+func (s *CatService) GetCatImageByHash(ctx context.Context, hash string) (*models.CatImage, error) {
+	return s.repo.FindByHash(ctx, hash)
+}
+
+func (s *CatService) DeleteImage(ctx context.Context, id uint) error {
+	if err := s.repo.SoftDelete(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete image: %w", err)
+	}
+	return nil
+}
+
+func (s *CatService) RestoreImage(ctx context.Context, id uint) (*models.CatImage, error) {
+	catImage, err := s.repo.Restore(ctx, id)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to save image: %w", err)
+		return nil, fmt.Errorf("failed to restore image: %w", err)
 	}
+	return catImage, nil
+}
 
-	
+// PurgeImage removes the image and its bytes for good.
+func (s *CatService) PurgeImage(ctx context.Context, id uint) error {
+	if err := s.repo.Purge(ctx, id); err != nil {
+		return fmt.Errorf("failed to purge image: %w", err)
+	}
+	return nil
+}
 
-	return catImage, response.Data, nil
+func (s *CatService) RecordAccess(ctx context.Context, catImage *models.CatImage) error {
+	return s.repo.RecordAccess(ctx, catImage)
 }
 
-func (s *CatService) GetCatImageByID(id uint) (*models.CatImage, error) {
-	return s.repo.FindByID(id)
+// OpenImageData returns a seekable reader over the image bytes; the caller
+// must close it.
+func (s *CatService) OpenImageData(ctx context.Context, catImage *models.CatImage) (io.ReadSeekCloser, error) {
+	reader, err := s.repo.OpenData(ctx, catImage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	return reader, nil
 }
 
-func (s *CatService) GetUniqueImageCount() (int64, error) {
-	count, err := s.repo.CountUnique()
+func (s *CatService) GetUniqueImageCount(ctx context.Context) (int64, error) {
+	count, err := s.repo.CountUnique(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get count: %w", err)
 	}
 	return count, nil
 }
 
-func (s *CatService) GetStats() (*models.CatImageStats, error) {
-	stats, err := s.repo.GetStats()
+func (s *CatService) GetStats(ctx context.Context) (*models.CatImageStats, error) {
+	stats, err := s.repo.GetStats(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get stats: %w", err)
 	}
+	stats.FallbackServed = s.fallbackServed.Load()
 	return stats, nil
 }
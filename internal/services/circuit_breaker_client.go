@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/IavilaGw/cat-api/internal/breaker"
+	"github.com/IavilaGw/cat-api/pkg/client"
+)
+
+// circuitBreakerClient fails fast with breaker.ErrOpen while CATAAS is
+// considered down instead of waiting out the HTTP timeout on every request.
+type circuitBreakerClient struct {
+	next    CataasClientInterface
+	breaker *breaker.Breaker
+}
+
+func NewCircuitBreakerClient(next CataasClientInterface, b *breaker.Breaker) CataasClientInterface {
+	return &circuitBreakerClient{next: next, breaker: b}
+}
+
+func (c *circuitBreakerClient) GetRandomCat(ctx context.Context, opts CatOptions) (*CatImageResponse, error) {
+	permit, err := c.breaker.Allow()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.next.GetRandomCat(ctx, opts)
+	switch {
+	case err == nil:
+		c.breaker.Record(permit, true)
+	case ctx.Err() != nil:
+		c.breaker.Release(permit)
+	default:
+		c.breaker.Record(permit, !isUpstreamFailure(err))
+	}
+	return resp, err
+}
+
+func (c *circuitBreakerClient) HealthCheck(ctx context.Context) error {
+	return c.next.HealthCheck(ctx)
+}
+
+// isUpstreamFailure separates an unhealthy upstream from requests it
+// rightly rejected, such as an unknown tag (404).
+func isUpstreamFailure(err error) bool {
+	var upstreamErr *client.UpstreamError
+	if errors.As(err, &upstreamErr) && upstreamErr.StatusCode >= 400 && upstreamErr.StatusCode < 500 {
+		return upstreamErr.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
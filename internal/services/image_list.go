@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/IavilaGw/cat-api/internal/models"
+)
+
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+var validSorts = map[models.ImageSort]bool{
+	models.SortCreatedAt:      true,
+	models.SortAccessCount:    true,
+	models.SortSize:           true,
+	models.SortLastAccessedAt: true,
+}
+
+// ListImagesOptions selects a page of GET /api/images. The zero value lists
+// the newest images first.
+type ListImagesOptions struct {
+	Limit         int
+	Cursor        string
+	Sort          string
+	Order         string
+	ContentType   string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// ImagePage is one page of image metadata. NextCursor is empty on the last
+// page.
+type ImagePage struct {
+	Images     []models.CatImage `json:"images"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// listCursor is the opaque cursor handed to clients. It remembers the sort
+// it was issued for so it cannot be replayed against a different order.
+type listCursor struct {
+	Sort string    `json:"s"`
+	Desc bool      `json:"d"`
+	ID   uint      `json:"id"`
+	Time time.Time `json:"t"`
+	Num  int64     `json:"n,omitempty"`
+}
+
+func (o ListImagesOptions) query() (models.ImageListQuery, error) {
+	q := models.ImageListQuery{
+		Sort:          models.SortCreatedAt,
+		Descending:    true,
+		ContentType:   o.ContentType,
+		CreatedAfter:  o.CreatedAfter,
+		CreatedBefore: o.CreatedBefore,
+		Limit:         o.Limit,
+	}
+
+	if o.Sort != "" {
+		q.Sort = models.ImageSort(o.Sort)
+		if !validSorts[q.Sort] {
+			return q, fmt.Errorf("%w: unknown sort %q", ErrInvalidOptions, o.Sort)
+		}
+	}
+	switch o.Order {
+	case "", "desc":
+	case "asc":
+		q.Descending = false
+	default:
+		return q, fmt.Errorf("%w: order must be asc or desc", ErrInvalidOptions)
+	}
+
+	if q.Limit == 0 {
+		q.Limit = DefaultListLimit
+	}
+	if q.Limit < 0 || q.Limit > MaxListLimit {
+		return q, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidOptions, MaxListLimit)
+	}
+	if !o.CreatedAfter.IsZero() && !o.CreatedBefore.IsZero() && !o.CreatedAfter.Before(o.CreatedBefore) {
+		return q, fmt.Errorf("%w: created_after must be before created_before", ErrInvalidOptions)
+	}
+
+	if o.Cursor != "" {
+		cursor, err := decodeCursor(o.Cursor)
+		if err != nil || cursor.Sort != string(q.Sort) || cursor.Desc != q.Descending {
+			return q, fmt.Errorf("%w: cursor does not belong to this listing", ErrInvalidOptions)
+		}
+		q.After = &models.ImageCursor{ID: cursor.ID, Time: cursor.Time, Num: cursor.Num}
+	}
+
+	return q, nil
+}
+
+func encodeCursor(q models.ImageListQuery, img *models.CatImage) string {
+	position := models.CursorFor(img, q.Sort)
+	data, _ := json.Marshal(listCursor{
+		Sort: string(q.Sort),
+		Desc: q.Descending,
+		ID:   position.ID,
+		Time: position.Time,
+		Num:  position.Num,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw string) (*listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	var cursor listCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// ListImages returns one page of stored image metadata.
+func (s *CatService) ListImages(ctx context.Context, opts ListImagesOptions) (*ImagePage, error) {
+	q, err := opts.query()
+	if err != nil {
+		return nil, err
+	}
+
+	// Ask for one extra row to learn whether another page exists.
+	limit := q.Limit
+	q.Limit++
+	images, err := s.repo.List(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+
+	page := &ImagePage{Images: images}
+	if len(images) > limit {
+		page.Images = images[:limit]
+		page.NextCursor = encodeCursor(q, &page.Images[limit-1])
+	}
+	if page.Images == nil {
+		page.Images = []models.CatImage{}
+	}
+	return page, nil
+}
@@ -1,23 +1,47 @@
 package services
 
 import (
+	"context"
+	"io"
+	"time"
+
 	"github.com/IavilaGw/cat-api/internal/models"
 )
 
 type CatRepositoryInterface interface {
-	Save(imageData []byte, contentType string) (*models.CatImage, error)
-	FindByID(id uint) (*models.CatImage, error)
-	CountUnique() (int64, error)
-	GetStats() (*models.CatImageStats, error)
+	// Save stores the image unless one with the same hash exists; created
+	// reports which of the two happened.
+	Save(ctx context.Context, imageData []byte, contentType string, variant models.CatVariant) (catImage *models.CatImage, created bool, err error)
+	FindByID(ctx context.Context, id uint) (*models.CatImage, error)
+	FindByHash(ctx context.Context, hash string) (*models.CatImage, error)
+	FindRandom(ctx context.Context, variant models.CatVariant) (*models.CatImage, error)
+	List(ctx context.Context, q models.ImageListQuery) ([]models.CatImage, error)
+	RecordAccess(ctx context.Context, catImage *models.CatImage) error
+	SoftDelete(ctx context.Context, id uint) error
+	Restore(ctx context.Context, id uint) (*models.CatImage, error)
+	Purge(ctx context.Context, id uint) error
+	LoadData(ctx context.Context, catImage *models.CatImage) ([]byte, error)
+	OpenData(ctx context.Context, catImage *models.CatImage) (io.ReadSeekCloser, error)
+	CountUnique(ctx context.Context) (int64, error)
+	GetStats(ctx context.Context) (*models.CatImageStats, error)
 }
 
 type CataasClientInterface interface {
-	GetRandomCat() (*CatImageResponse, error)
-	HealthCheck() error
+	GetRandomCat(ctx context.Context, opts CatOptions) (*CatImageResponse, error)
+	HealthCheck(ctx context.Context) error
 }
 
 type CatImageResponse struct {
 	Data        []byte
 	ContentType string
 	Size        int64
+	Attempts    int
+}
+
+type APIKeyRepositoryInterface interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	FindByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	List(ctx context.Context) ([]models.APIKey, error)
+	Revoke(ctx context.Context, id uint) error
+	TouchLastUsed(ctx context.Context, id uint, at time.Time) error
 }
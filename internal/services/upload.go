@@ -0,0 +1,108 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/IavilaGw/cat-api/internal/models"
+	"go.opentelemetry.io/otel/attribute"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrImageTooLarge    = errors.New("image too large")
+	ErrUnsupportedImage = errors.New("unsupported image type")
+	ErrInvalidImage     = errors.New("invalid image")
+)
+
+// uploadFormats maps the sniffed content type to the name image.Decode
+// reports for it, so a payload cannot pass as one format and decode as
+// another.
+var uploadFormats = map[string]string{
+	"image/jpeg": "jpeg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+type UploadLimits struct {
+	MaxBytes  int64
+	MaxPixels int
+}
+
+var defaultUploadLimits = UploadLimits{
+	MaxBytes:  10 << 20,
+	MaxPixels: 40_000_000,
+}
+
+func (s *CatService) WithUploadLimits(limits UploadLimits) *CatService {
+	s.uploadLimits = limits
+	return s
+}
+
+// UploadImage validates an image sent by a client and stores it with the
+// same SHA-256 dedup as CATAAS images. The content type is sniffed from the
+// bytes; whatever the client claimed is ignored.
+func (s *CatService) UploadImage(ctx context.Context, r io.Reader) (catImage *models.CatImage, created bool, err error) {
+	ctx, span := tracer.Start(ctx, "CatService.UploadImage")
+	defer func() { endSpan(span, err) }()
+
+	data, err := io.ReadAll(io.LimitReader(r, s.uploadLimits.MaxBytes+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read upload: %w", err)
+	}
+	if int64(len(data)) > s.uploadLimits.MaxBytes {
+		return nil, false, fmt.Errorf("%w: limit is %d bytes", ErrImageTooLarge, s.uploadLimits.MaxBytes)
+	}
+	span.SetAttributes(attribute.Int("cat.size_bytes", len(data)))
+
+	contentType, err := validateImage(data, s.uploadLimits.MaxPixels)
+	if err != nil {
+		return nil, false, err
+	}
+
+	catImage, created, err = s.repo.Save(ctx, data, contentType, models.CatVariant{})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to save image: %w", err)
+	}
+	span.SetAttributes(attribute.Bool("cat.dedup_hit", !created))
+
+	return catImage, created, nil
+}
+
+func validateImage(data []byte, maxPixels int) (string, error) {
+	if len(data) == 0 {
+		return "", fmt.Errorf("%w: empty body", ErrInvalidImage)
+	}
+
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	format, ok := uploadFormats[contentType]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedImage, contentType)
+	}
+
+	// Check the dimensions before decoding so a small file cannot expand
+	// into a huge bitmap.
+	config, decoded, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || decoded != format {
+		return "", fmt.Errorf("%w: not a valid %s", ErrInvalidImage, format)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPixels {
+		return "", fmt.Errorf("%w: %dx%d exceeds %d pixels", ErrInvalidImage, config.Width, config.Height, maxPixels)
+	}
+
+	if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	return contentType, nil
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/IavilaGw/cat-api/internal/config"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps image bytes outside of Postgres. Keys are derived from the
+// content hash, so Put is idempotent for the same image.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Open returns a seekable reader so range requests only read what they
+	// need. Callers must close it.
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	Delete(ctx context.Context, key string) error
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+func NewBlobStore(cfg *config.BlobStoreConfig) (BlobStore, error) {
+	switch cfg.Backend {
+	case "local":
+		return NewLocalStore(cfg.LocalPath)
+	case "s3":
+		return NewS3Store(S3Options{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			UseSSL:    cfg.S3UseSSL,
+		})
+	default:
+		return nil, fmt.Errorf("unknown blob store backend: %q", cfg.Backend)
+	}
+}
+
+// KeyForHash spreads blobs over 256 prefixes to keep directories small.
+func KeyForHash(hash string) string {
+	if len(hash) < 2 {
+		return hash
+	}
+	return hash[:2] + "/" + hash
+}
@@ -0,0 +1,70 @@
+package telemetry
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "telemetry:span"
+
+// InstrumentGORM opens a client span around every GORM operation. Spans are
+// children of whatever span is in the statement context, so repositories only
+// need to use db.WithContext(ctx).
+func InstrumentGORM(db *gorm.DB) error {
+	tracer := otel.Tracer("github.com/IavilaGw/cat-api/internal/database")
+
+	before := func(op string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			ctx, span := tracer.Start(tx.Statement.Context, "gorm."+op,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					semconv.DBSystemPostgreSQL,
+					semconv.DBOperationName(op),
+				),
+			)
+			tx.Statement.Context = ctx
+			tx.InstanceSet(spanKey, span)
+		}
+	}
+
+	after := func(tx *gorm.DB) {
+		value, ok := tx.InstanceGet(spanKey)
+		if !ok {
+			return
+		}
+		span := value.(trace.Span)
+		defer span.End()
+
+		span.SetAttributes(
+			semconv.DBCollectionName(tx.Statement.Table),
+			semconv.DBQueryText(tx.Statement.SQL.String()),
+			attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+		)
+		if tx.Error != nil && tx.Error != gorm.ErrRecordNotFound {
+			span.RecordError(tx.Error)
+			span.SetStatus(codes.Error, tx.Error.Error())
+		}
+	}
+
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("telemetry:before_create", before("create")),
+		cb.Create().After("gorm:create").Register("telemetry:after_create", after),
+		cb.Query().Before("gorm:query").Register("telemetry:before_query", before("query")),
+		cb.Query().After("gorm:query").Register("telemetry:after_query", after),
+		cb.Update().Before("gorm:update").Register("telemetry:before_update", before("update")),
+		cb.Update().After("gorm:update").Register("telemetry:after_update", after),
+		cb.Delete().Before("gorm:delete").Register("telemetry:before_delete", before("delete")),
+		cb.Delete().After("gorm:delete").Register("telemetry:after_delete", after),
+		cb.Row().Before("gorm:row").Register("telemetry:before_row", before("row")),
+		cb.Row().After("gorm:row").Register("telemetry:after_row", after),
+		cb.Raw().Before("gorm:raw").Register("telemetry:before_raw", before("raw")),
+		cb.Raw().After("gorm:raw").Register("telemetry:after_raw", after),
+	)
+}
@@ -0,0 +1,127 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reloader serves the certificate in certFile/keyFile and picks up new
+// versions of the files without a restart, e.g. after a renewal.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	logger   *slog.Logger
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	version [2]fileVersion
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewReloader loads the certificate once and fails if it cannot.
+func NewReloader(certFile, keyFile string, logger *slog.Logger) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		logger:   logger,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate is meant for tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Reload loads the files again if either changed since the last successful
+// load. On error the previous certificate stays in use.
+func (r *Reloader) Reload() (bool, error) {
+	version, err := r.stat()
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	unchanged := r.cert != nil && version == r.version
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.version = version
+	r.mu.Unlock()
+	return true, nil
+}
+
+func (r *Reloader) stat() ([2]fileVersion, error) {
+	var version [2]fileVersion
+	for i, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return version, fmt.Errorf("failed to read TLS file: %w", err)
+		}
+		version[i] = fileVersion{modTime: info.ModTime(), size: info.Size()}
+	}
+	return version, nil
+}
+
+// Start checks the files every interval until Stop is called.
+func (r *Reloader) Start(interval time.Duration) {
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+			}
+
+			reloaded, err := r.Reload()
+			if err != nil {
+				// Renewals often rewrite the two files one after the other,
+				// so a mismatch is retried on the next tick.
+				r.logger.Error("failed to reload TLS certificate, keeping the previous one", "error", err)
+				continue
+			}
+			if reloaded {
+				r.logger.Info("reloaded TLS certificate", "cert_file", r.certFile)
+			}
+		}
+	}()
+}
+
+// Stop ends the watch started by Start and waits for it to exit.
+func (r *Reloader) Stop() {
+	r.once.Do(func() { close(r.stop) })
+	<-r.done
+}
@@ -0,0 +1,71 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// Options describes the server side of TLS.
+type Options struct {
+	// MinVersion is "1.2" or "1.3".
+	MinVersion string
+	// ClientCAFile enables client certificate (mTLS) verification against
+	// the CAs in this PEM file.
+	ClientCAFile string
+	// ClientAuth is "require" or "optional" and only applies with a
+	// ClientCAFile; empty means "require".
+	ClientAuth string
+}
+
+// New builds a server tls.Config that takes its certificate from reloader.
+func New(opts Options, reloader *Reloader) (*tls.Config, error) {
+	minVersion, err := parseVersion(opts.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		MinVersion:     minVersion,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if opts.ClientCAFile == "" {
+		if opts.ClientAuth != "" {
+			return nil, fmt.Errorf("TLS client auth %q needs a client CA file", opts.ClientAuth)
+		}
+		return cfg, nil
+	}
+
+	pem, err := os.ReadFile(opts.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in client CA file %s", opts.ClientCAFile)
+	}
+	cfg.ClientCAs = pool
+
+	switch opts.ClientAuth {
+	case "", "require":
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	case "optional":
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	default:
+		return nil, fmt.Errorf("unknown TLS client auth %q (want require or optional)", opts.ClientAuth)
+	}
+	return cfg, nil
+}
+
+func parseVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS min version %q (want 1.2 or 1.3)", version)
+	}
+}
@@ -1,21 +1,41 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 type CataasClient struct {
 	baseURL    string
 	httpClient *http.Client
+	retry      RetryPolicy
+	logger     *slog.Logger
 }
 
 type CatImageResponse struct {
 	Data        []byte
 	ContentType string
 	Size        int64
+	Attempts    int
+}
+
+// CatOptions selects the variant of the image requested from CATAAS.
+// Zero values are omitted from the upstream request.
+type CatOptions struct {
+	Tag    string
+	Says   string
+	Filter string
+	Width  int
+	Height int
+	Type   string
 }
 
 func NewCataasClient(baseURL string, timeoutSeconds int) *CataasClient {
@@ -23,26 +43,84 @@ func NewCataasClient(baseURL string, timeoutSeconds int) *CataasClient {
 		baseURL: baseURL,
 		httpClient: &http.Client{
 			Timeout: time.Duration(timeoutSeconds) * time.Second,
+			// Creates a client span per attempt and injects traceparent.
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
 		},
+		retry:  RetryPolicy{MaxAttempts: 1},
+		logger: slog.Default(),
+	}
+}
+
+func (c *CataasClient) WithLogger(logger *slog.Logger) *CataasClient {
+	c.logger = logger
+	return c
+}
+
+// WithRetryPolicy enables retries for GetRandomCat. The zero policy (or
+// MaxAttempts <= 1) keeps the single-attempt behaviour.
+func (c *CataasClient) WithRetryPolicy(policy RetryPolicy) *CataasClient {
+	c.retry = policy
+	return c
+}
+
+func (c *CataasClient) GetRandomCat(ctx context.Context, opts CatOptions) (*CatImageResponse, error) {
+	url := c.catURL(opts)
+	maxAttempts := max(c.retry.MaxAttempts, 1)
+
+	for attempt := 1; ; attempt++ {
+		resp, retryAfter, statusCode, err := c.fetch(ctx, url)
+		if err == nil {
+			resp.Attempts = attempt
+			return resp, nil
+		}
+
+		if attempt >= maxAttempts || !retryable(ctx, statusCode, err) {
+			return nil, &UpstreamError{StatusCode: statusCode, Attempts: attempt, Err: err}
+		}
+
+		wait := c.retry.backoff(attempt)
+		if retryAfter > 0 {
+			// Never retry sooner than the upstream asked; give up if it asks
+			// for longer than we are willing to wait.
+			if retryAfter > c.retry.MaxBackoff {
+				return nil, &UpstreamError{StatusCode: statusCode, Attempts: attempt, Err: err}
+			}
+			wait = max(wait, retryAfter)
+		}
+
+		c.logger.WarnContext(ctx, "retrying cataas request",
+			"attempt", attempt,
+			"status", statusCode,
+			"wait", wait,
+			"error", err,
+		)
+
+		if err := sleepContext(ctx, wait); err != nil {
+			return nil, &UpstreamError{StatusCode: statusCode, Attempts: attempt, Err: err}
+		}
 	}
 }
 
-func (c *CataasClient) GetRandomCat() (*CatImageResponse, error) {
-	url := fmt.Sprintf("%s/cat", c.baseURL)
+func (c *CataasClient) fetch(ctx context.Context, url string) (*CatImageResponse, time.Duration, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to build request: %w", err)
+	}
 
-	resp, err := c.httpClient.Get(url)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch cat image: %w", err)
+		return nil, 0, 0, fmt.Errorf("failed to fetch cat image: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		retryAfter, _ := parseRetryAfter(resp.Header.Get("Retry-After"))
+		return nil, retryAfter, resp.StatusCode, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, 0, 0, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	contentType := resp.Header.Get("Content-Type")
@@ -54,13 +132,16 @@ func (c *CataasClient) GetRandomCat() (*CatImageResponse, error) {
 		Data:        data,
 		ContentType: contentType,
 		Size:        int64(len(data)),
-	}, nil
+	}, 0, resp.StatusCode, nil
 }
 
-func (c *CataasClient) HealthCheck() error {
-	url := fmt.Sprintf("%s/cat", c.baseURL)
-	
-	resp, err := c.httpClient.Head(url)
+func (c *CataasClient) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, fmt.Sprintf("%s/cat", c.baseURL), nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("api not reachable: %w", err)
 	}
@@ -71,4 +152,35 @@ func (c *CataasClient) HealthCheck() error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// catURL builds /cat, /cat/{tag}, /cat/says/{text} or /cat/{tag}/says/{text}
+// plus the query options understood by CATAAS.
+func (c *CataasClient) catURL(opts CatOptions) string {
+	path := "/cat"
+	if opts.Tag != "" {
+		path += "/" + url.PathEscape(opts.Tag)
+	}
+	if opts.Says != "" {
+		path += "/says/" + url.PathEscape(opts.Says)
+	}
+
+	query := url.Values{}
+	if opts.Filter != "" {
+		query.Set("filter", opts.Filter)
+	}
+	if opts.Width > 0 {
+		query.Set("width", strconv.Itoa(opts.Width))
+	}
+	if opts.Height > 0 {
+		query.Set("height", strconv.Itoa(opts.Height))
+	}
+	if opts.Type != "" {
+		query.Set("type", opts.Type)
+	}
+
+	if len(query) == 0 {
+		return c.baseURL + path
+	}
+	return c.baseURL + path + "?" + query.Encode()
+}
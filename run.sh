@@ -34,8 +34,15 @@ DB_USER=postgres
 DB_PASSWORD=postgres
 DB_NAME=catdb
 DB_SSLMODE=disable
+AUTO_MIGRATE=true
 CATAAS_API_URL=https://cataas.com
 TIMEOUT_SECONDS=30
+BLOB_STORE_BACKEND=local
+BLOB_STORE_PATH=./data/images
+LOG_LEVEL=info
+LOG_FORMAT=json
+AUTH_ENABLED=true
+AUTH_PUBLIC_READ=false
 ENVEOF
         fi
         echo "Archivo .env creado"
@@ -76,6 +83,12 @@ restart_services() {
 
 test_endpoints() {
     echo "Probando endpoints..."
+
+    # Con AUTH_ENABLED=true las rutas /api necesitan una clave: API_KEY=cat_... ./run.sh test
+    auth=()
+    if [ -n "${API_KEY:-}" ]; then
+        auth=(-H "X-API-Key: $API_KEY")
+    fi
     
     if curl -s http://localhost:8080/health | grep -q "healthy"; then
         echo "Health: OK"
@@ -83,17 +96,17 @@ test_endpoints() {
         echo "Health: FAIL"
     fi
     
-    if curl -s -o /tmp/test.jpg http://localhost:8080/api/cat; then
+    if curl -s "${auth[@]}" -o /tmp/test.jpg http://localhost:8080/api/cat; then
         echo "Get cat: OK"
         rm -f /tmp/test.jpg
     else
         echo "Get cat: FAIL"
     fi
     
-    count=$(curl -s http://localhost:8080/api/count)
+    count=$(curl -s "${auth[@]}" http://localhost:8080/api/count)
     echo "Count: $count"
     
-    if curl -s http://localhost:8080/api/stats | grep -q "total_images"; then
+    if curl -s "${auth[@]}" http://localhost:8080/api/stats | grep -q "total_images"; then
         echo "Stats: OK"
     else
         echo "Stats: FAIL"
@@ -0,0 +1,45 @@
+package integration_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IavilaGw/cat-api/internal/database"
+)
+
+func TestAdvisoryLock_OneHolderAtATime(t *testing.T) {
+	testDB, err := database.NewDatabase(testDBConfig)
+	if err != nil {
+		t.Skip("Database not available:", err)
+		return
+	}
+	defer testDB.Close()
+
+	ctx := context.Background()
+	if err := testDB.HealthCheck(ctx); err != nil {
+		t.Skip("Database not available:", err)
+		return
+	}
+
+	// Dos replicas con la misma clave
+	first, err := database.NewAdvisoryLock(testDB, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := database.NewAdvisoryLock(testDB, 42)
+
+	unlock, ok, err := first.TryLock(ctx)
+	if err != nil || !ok {
+		t.Fatalf("Expected to take the lock, got ok=%v (%v)", ok, err)
+	}
+	if _, ok, err := second.TryLock(ctx); err != nil || ok {
+		t.Fatalf("Expected the lock to be held, got ok=%v (%v)", ok, err)
+	}
+
+	unlock()
+	unlockAgain, ok, err := second.TryLock(ctx)
+	if err != nil || !ok {
+		t.Fatalf("Expected the lock to be free after unlock, got ok=%v (%v)", ok, err)
+	}
+	unlockAgain()
+}
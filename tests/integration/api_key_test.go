@@ -0,0 +1,57 @@
+package integration_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/IavilaGw/cat-api/internal/database"
+	"github.com/IavilaGw/cat-api/internal/models"
+	"github.com/IavilaGw/cat-api/internal/repositories"
+	"github.com/IavilaGw/cat-api/internal/services"
+)
+
+func TestAPIKeyRepository_Lifecycle(t *testing.T) {
+	testDB, err := database.NewDatabase(testDBConfig)
+	if err != nil {
+		t.Skip("Database not available:", err)
+	}
+	if err := migrateTestDB(testDB); err != nil {
+		testDB.Close()
+		t.Skip("Database not available:", err)
+	}
+	t.Cleanup(func() {
+		testDB.DB.Exec("DELETE FROM api_keys")
+		testDB.Close()
+	})
+
+	ctx := context.Background()
+	repo := repositories.NewAPIKeyRepository(testDB.DB)
+	svc := services.NewAPIKeyService(repo, slog.Default())
+
+	plaintext, key, err := svc.CreateKey(ctx, "integracion", []models.APIScope{models.ScopeFetch}, 0)
+	if err != nil {
+		t.Fatalf("Expected no error on create, got %v", err)
+	}
+
+	found, err := svc.Authenticate(ctx, plaintext)
+	if err != nil || found.ID != key.ID || !found.HasScope(models.ScopeFetch) {
+		t.Fatalf("Expected the key to authenticate, got %+v (%v)", found, err)
+	}
+
+	keys, err := repo.List(ctx)
+	if err != nil || len(keys) != 1 || keys[0].LastUsedAt == nil {
+		t.Errorf("Expected one key with last_used_at set, got %+v (%v)", keys, err)
+	}
+
+	if err := repo.Revoke(ctx, key.ID); err != nil {
+		t.Fatalf("Expected no error on revoke, got %v", err)
+	}
+	if err := repo.Revoke(ctx, key.ID); !errors.Is(err, repositories.ErrAPIKeyNotFound) {
+		t.Errorf("Expected ErrAPIKeyNotFound revoking twice, got %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, plaintext); !errors.Is(err, services.ErrInvalidAPIKey) {
+		t.Errorf("Expected a revoked key to be rejected, got %v", err)
+	}
+}
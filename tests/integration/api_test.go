@@ -2,7 +2,10 @@
 package integration_test
 
 import (
+	"context"
+	"log/slog"
 	"net/http"
+	"os"
 	"net/http/httptest"
 	"testing"
 
@@ -12,6 +15,7 @@ import (
 	"github.com/IavilaGw/cat-api/internal/handlers"
 	"github.com/IavilaGw/cat-api/internal/repositories"
 	"github.com/IavilaGw/cat-api/internal/services"
+	"github.com/IavilaGw/cat-api/internal/storage"
 	"github.com/IavilaGw/cat-api/pkg/client"
 )
 
@@ -37,19 +41,24 @@ func setupTestRouter() (*gin.Engine, error) {
 	}
 
 	// Ejecutar migraciones
-	if err := testDB.AutoMigrate(); err != nil {
+	if err := migrateTestDB(testDB); err != nil {
 		return nil, err
 	}
 
 	db = testDB
 
+	blobStore, err := storage.NewLocalStore(os.TempDir() + "/cat-api-test-blobs")
+	if err != nil {
+		return nil, err
+	}
+
 	// Setup servicios
 	cataasClient := client.NewCataasClient("https://cataas.com", 30)
-	catRepo := repositories.NewCatRepository(testDB.DB)
-	catService := services.NewCatServiceWithConcrete(catRepo, cataasClient)
+	catRepo := repositories.NewCatRepository(testDB.DB, blobStore)
+	catService := services.NewCatServiceWithConcrete(catRepo, cataasClient, slog.Default())
 
-	catHandler := handlers.NewCatHandler(catService)
-	healthHandler := handlers.NewHealthHandler(testDB, cataasClient)
+	catHandler := handlers.NewCatHandler(catService, slog.Default())
+	healthHandler := handlers.NewHealthHandler(testDB, cataasClient, nil)
 
 	// Setup router
 	r := gin.New()
@@ -66,6 +75,15 @@ func setupTestRouter() (*gin.Engine, error) {
 	return r, nil
 }
 
+func migrateTestDB(testDB *database.Database) error {
+	migrator, err := database.NewMigrator(testDB)
+	if err != nil {
+		return err
+	}
+	_, err = migrator.Up(context.Background())
+	return err
+}
+
 func teardownTest() {
 	if db != nil {
 		db.DB.Exec("DELETE FROM cat_images")
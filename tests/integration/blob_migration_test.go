@@ -0,0 +1,70 @@
+package integration_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/IavilaGw/cat-api/internal/database"
+	"github.com/IavilaGw/cat-api/internal/repositories"
+	"github.com/IavilaGw/cat-api/internal/storage"
+)
+
+func TestBlobMigrator(t *testing.T) {
+	testDB, err := database.NewDatabase(testDBConfig)
+	if err != nil {
+		t.Skip("Database not available:", err)
+		return
+	}
+	defer testDB.Close()
+
+	if err := migrateTestDB(testDB); err != nil {
+		t.Skip("Database not available:", err)
+		return
+	}
+	defer testDB.DB.Exec("DELETE FROM cat_images")
+
+	// Simular una tabla anterior al blob store con bytes en image_data
+	testDB.DB.Exec("ALTER TABLE cat_images ADD COLUMN IF NOT EXISTS image_data bytea")
+
+	good := []byte("legacy-cat")
+	sum := sha256.Sum256(good)
+	goodHash := hex.EncodeToString(sum[:])
+
+	insert := "INSERT INTO cat_images (image_hash, content_type, size, created_at, last_accessed_at, image_data) VALUES (?, 'image/jpeg', ?, now(), now(), ?)"
+	if err := testDB.DB.Exec(insert, goodHash, len(good), good).Error; err != nil {
+		t.Fatalf("Failed to insert legacy row: %v", err)
+	}
+	if err := testDB.DB.Exec(insert, "corrupted-hash", 3, []byte("bad")).Error; err != nil {
+		t.Fatalf("Failed to insert legacy row: %v", err)
+	}
+
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	migrator := repositories.NewBlobMigrator(testDB.DB, store, 1)
+	progress, err := migrator.Run(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if progress.Migrated != 1 || progress.Failed != 1 {
+		t.Errorf("Expected 1 migrated and 1 failed, got %+v", progress)
+	}
+
+	data, err := store.Get(context.Background(), storage.KeyForHash(goodHash))
+	if err != nil || string(data) != "legacy-cat" {
+		t.Errorf("Expected migrated blob, got %q (%v)", data, err)
+	}
+
+	pending, err := migrator.Pending(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if pending != 1 {
+		t.Errorf("Expected corrupted row to keep its bytes, got %d pending", pending)
+	}
+}
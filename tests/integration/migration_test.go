@@ -0,0 +1,57 @@
+package integration_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IavilaGw/cat-api/internal/database"
+)
+
+func TestMigrator_UpDownStatus(t *testing.T) {
+	testDB, err := database.NewDatabase(testDBConfig)
+	if err != nil {
+		t.Skip("Database not available:", err)
+		return
+	}
+	defer testDB.Close()
+
+	if err := testDB.HealthCheck(context.Background()); err != nil {
+		t.Skip("Database not available:", err)
+		return
+	}
+
+	migrator, err := database.NewMigrator(testDB)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	ctx := context.Background()
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Expected no error on up, got %v", err)
+	}
+
+	pending, err := migrator.Pending(ctx)
+	if err != nil || pending != 0 {
+		t.Fatalf("Expected no pending migrations, got %d (%v)", pending, err)
+	}
+
+	// Up es idempotente
+	applied, err := migrator.Up(ctx)
+	if err != nil || len(applied) != 0 {
+		t.Errorf("Expected second up to be a no-op, got %d applied (%v)", len(applied), err)
+	}
+
+	reverted, err := migrator.Down(ctx, 1)
+	if err != nil || len(reverted) != 1 {
+		t.Fatalf("Expected one migration reverted, got %d (%v)", len(reverted), err)
+	}
+
+	pending, err = migrator.Pending(ctx)
+	if err != nil || pending != 1 {
+		t.Errorf("Expected one pending migration, got %d (%v)", pending, err)
+	}
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Expected no error re-applying, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package integration_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IavilaGw/cat-api/internal/database"
+	"github.com/IavilaGw/cat-api/internal/ratelimit"
+)
+
+func TestPostgresStore_SharedBetweenReplicas(t *testing.T) {
+	testDB, err := database.NewDatabase(testDBConfig)
+	if err != nil {
+		t.Skip("Database not available:", err)
+	}
+	if err := migrateTestDB(testDB); err != nil {
+		testDB.Close()
+		t.Skip("Database not available:", err)
+	}
+	t.Cleanup(func() {
+		testDB.DB.Exec("DELETE FROM rate_limit_buckets")
+		testDB.Close()
+	})
+
+	// Dos stores sobre la misma tabla simulan dos replicas
+	replicas := []*ratelimit.PostgresStore{ratelimit.NewPostgresStore(testDB.DB), ratelimit.NewPostgresStore(testDB.DB)}
+	limit := ratelimit.Limit{Rate: 10, Burst: 10}
+	ctx := context.Background()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			d, err := replicas[i%2].Take(ctx, "fetch:ip:192.0.2.1", limit)
+			if err != nil {
+				t.Errorf("Take failed: %v", err)
+				return
+			}
+			if d.Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Las peticiones llegan en unos pocos ms, asi que casi no hay recarga
+	if allowed < 10 || allowed > 11 {
+		t.Errorf("Expected the burst of 10 to be shared by both replicas, got %d allowed", allowed)
+	}
+
+	d, err := replicas[0].Take(ctx, "fetch:ip:192.0.2.1", limit)
+	if err == nil && d.Allowed {
+		t.Error("Expected the bucket to be empty")
+	} else if err == nil && (d.RetryAfter <= 0 || d.RetryAfter > 100*time.Millisecond) {
+		t.Errorf("Expected RetryAfter within one token, got %v", d.RetryAfter)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if d, err := replicas[1].Take(ctx, "fetch:ip:192.0.2.1", limit); err != nil || !d.Allowed {
+		t.Errorf("Expected a token after refill, got %+v (%v)", d, err)
+	}
+}
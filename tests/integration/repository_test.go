@@ -0,0 +1,312 @@
+package integration_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IavilaGw/cat-api/internal/database"
+	"github.com/IavilaGw/cat-api/internal/models"
+	"github.com/IavilaGw/cat-api/internal/repositories"
+	"github.com/IavilaGw/cat-api/internal/storage"
+)
+
+// setupRepository devuelve un repositorio sobre la base de prueba migrada y
+// un blob store temporal.
+func setupRepository(t *testing.T) (*database.Database, *repositories.CatRepository) {
+	t.Helper()
+
+	testDB, err := database.NewDatabase(testDBConfig)
+	if err != nil {
+		t.Skip("Database not available:", err)
+	}
+	if err := migrateTestDB(testDB); err != nil {
+		testDB.Close()
+		t.Skip("Database not available:", err)
+	}
+	t.Cleanup(func() {
+		testDB.DB.Exec("DELETE FROM cat_images")
+		testDB.Close()
+	})
+
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	return testDB, repositories.NewCatRepository(testDB.DB, store)
+}
+
+func TestCatRepository_RecordAccessAndOpenData(t *testing.T) {
+	_, repo := setupRepository(t)
+	ctx := context.Background()
+
+	saved, _, err := repo.Save(ctx, []byte("0123456789"), "image/gif", models.CatVariant{})
+	if err != nil {
+		t.Fatalf("Expected no error on save, got %v", err)
+	}
+
+	// Leer la imagen no cuenta accesos; solo RecordAccess lo hace
+	found, err := repo.FindByID(ctx, saved.ID)
+	if err != nil {
+		t.Fatalf("Expected no error on find, got %v", err)
+	}
+	if err := repo.RecordAccess(ctx, found); err != nil {
+		t.Fatalf("Expected no error on record, got %v", err)
+	}
+
+	again, err := repo.FindByID(ctx, saved.ID)
+	if err != nil {
+		t.Fatalf("Expected no error on find, got %v", err)
+	}
+	if again.AccessCount != saved.AccessCount+1 || found.AccessCount != again.AccessCount {
+		t.Errorf("Expected access count %d, got %d (in memory %d)", saved.AccessCount+1, again.AccessCount, found.AccessCount)
+	}
+
+	reader, err := repo.OpenData(ctx, again)
+	if err != nil {
+		t.Fatalf("Expected no error on open, got %v", err)
+	}
+	defer reader.Close()
+
+	if _, err := reader.Seek(6, io.SeekStart); err != nil {
+		t.Fatalf("Expected no error on seek, got %v", err)
+	}
+	rest, _ := io.ReadAll(reader)
+	if string(rest) != "6789" {
+		t.Errorf("Expected '6789', got %q", rest)
+	}
+}
+
+func TestCatRepository_List(t *testing.T) {
+	_, repo := setupRepository(t)
+	ctx := context.Background()
+
+	var saved []*models.CatImage
+	for i, contentType := range []string{"image/jpeg", "image/gif", "image/jpeg", "image/gif", "image/jpeg"} {
+		img, _, err := repo.Save(ctx, []byte{byte(i), 'c', 'a', 't'}, contentType, models.CatVariant{})
+		if err != nil {
+			t.Fatalf("Expected no error on save, got %v", err)
+		}
+		saved = append(saved, img)
+	}
+
+	// Recorrer todas las paginas de a dos, del mas nuevo al mas viejo
+	q := models.ImageListQuery{Sort: models.SortCreatedAt, Descending: true, Limit: 2}
+	var ids []uint
+	for {
+		page, err := repo.List(ctx, q)
+		if err != nil {
+			t.Fatalf("Expected no error on list, got %v", err)
+		}
+		for _, img := range page {
+			ids = append(ids, img.ID)
+		}
+		if len(page) < q.Limit {
+			break
+		}
+		cursor := models.CursorFor(&page[len(page)-1], q.Sort)
+		q.After = &cursor
+	}
+
+	if len(ids) != len(saved) {
+		t.Fatalf("Expected %d images, got %v", len(saved), ids)
+	}
+	for i, img := range saved {
+		if ids[len(ids)-1-i] != img.ID {
+			t.Errorf("Expected newest first, got %v", ids)
+			break
+		}
+	}
+
+	gifs, err := repo.List(ctx, models.ImageListQuery{Sort: models.SortSize, ContentType: "image/gif", Limit: 10})
+	if err != nil {
+		t.Fatalf("Expected no error on list, got %v", err)
+	}
+	if len(gifs) != 2 {
+		t.Errorf("Expected 2 gifs, got %d", len(gifs))
+	}
+}
+
+func TestCatRepository_FindRandomVariant(t *testing.T) {
+	_, repo := setupRepository(t)
+	ctx := context.Background()
+
+	captioned := models.CatVariant{Says: "hola"}
+	saved, _, err := repo.Save(ctx, []byte("captioned"), "image/jpeg", captioned)
+	if err != nil {
+		t.Fatalf("Expected no error on save, got %v", err)
+	}
+
+	// Solo hay una imagen con texto: una peticion sin opciones no la recibe
+	if _, err := repo.FindRandom(ctx, models.CatVariant{}); !errors.Is(err, repositories.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a plain request, got %v", err)
+	}
+	found, err := repo.FindRandom(ctx, captioned)
+	if err != nil || found.ID != saved.ID {
+		t.Errorf("Expected the captioned image, got %v, %v", found, err)
+	}
+}
+
+func TestCatRepository_SoftDeleteRestorePurge(t *testing.T) {
+	testDB, repo := setupRepository(t)
+	ctx := context.Background()
+	data := []byte("deleted-cat")
+
+	saved, _, err := repo.Save(ctx, data, "image/jpeg", models.CatVariant{})
+	if err != nil {
+		t.Fatalf("Expected no error on save, got %v", err)
+	}
+
+	if err := repo.SoftDelete(ctx, saved.ID); err != nil {
+		t.Fatalf("Expected no error on delete, got %v", err)
+	}
+	if _, err := repo.FindByID(ctx, saved.ID); !errors.Is(err, repositories.ErrNotFound) {
+		t.Errorf("Expected deleted image to be hidden, got %v", err)
+	}
+	if err := repo.SoftDelete(ctx, saved.ID); !errors.Is(err, repositories.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting twice, got %v", err)
+	}
+
+	// Con la politica por defecto la imagen sigue oculta
+	if _, _, err := repo.Save(ctx, data, "image/jpeg", models.CatVariant{}); !errors.Is(err, repositories.ErrImageDeleted) {
+		t.Errorf("Expected ErrImageDeleted, got %v", err)
+	}
+
+	// Con restore vuelve a aparecer con el mismo ID
+	repo.WithDeletedImagePolicy(repositories.DeletedImageRestore)
+	again, created, err := repo.Save(ctx, data, "image/jpeg", models.CatVariant{})
+	if err != nil || created || again.ID != saved.ID {
+		t.Fatalf("Expected the deleted image to be resurrected, got %+v created=%v (%v)", again, created, err)
+	}
+	if _, err := repo.FindByID(ctx, saved.ID); err != nil {
+		t.Errorf("Expected resurrected image to be visible, got %v", err)
+	}
+
+	repo.SoftDelete(ctx, saved.ID)
+	restored, err := repo.Restore(ctx, saved.ID)
+	if err != nil || restored.ID != saved.ID {
+		t.Fatalf("Expected restore to work, got %v", err)
+	}
+
+	if err := repo.Purge(ctx, saved.ID); err != nil {
+		t.Fatalf("Expected no error on purge, got %v", err)
+	}
+	var remaining int64
+	testDB.DB.Unscoped().Model(&models.CatImage{}).Where("id = ?", saved.ID).Count(&remaining)
+	if remaining != 0 {
+		t.Error("Expected purged row to be gone")
+	}
+	if _, err := repo.OpenData(ctx, saved); err == nil {
+		t.Error("Expected purged bytes to be gone")
+	}
+	if err := repo.Purge(ctx, saved.ID); !errors.Is(err, repositories.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound purging twice, got %v", err)
+	}
+}
+
+func TestCatRepository_RetentionQueries(t *testing.T) {
+	testDB, repo := setupRepository(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	// La 1 es la menos reciente pero la mas vista; la 3 esta borrada
+	var ids []uint
+	for i, access := range []struct {
+		count int
+		ago   time.Duration
+	}{{50, 4 * time.Hour}, {1, 3 * time.Hour}, {2, 2 * time.Hour}} {
+		img, _, err := repo.Save(ctx, []byte{byte(i), 'o', 'l', 'd'}, "image/jpeg", models.CatVariant{})
+		if err != nil {
+			t.Fatalf("Expected no error on save, got %v", err)
+		}
+		testDB.DB.Model(img).Updates(map[string]interface{}{
+			"access_count":     access.count,
+			"last_accessed_at": now.Add(-access.ago),
+		})
+		ids = append(ids, img.ID)
+	}
+	repo.SoftDelete(ctx, ids[2])
+
+	count, total, err := repo.RetentionTotals(ctx)
+	if err != nil || count != 3 || total != 12 {
+		t.Errorf("Expected 3 images / 12 bytes including deleted, got %d / %d (%v)", count, total, err)
+	}
+
+	expired, err := repo.ExpiredImages(ctx, now.Add(-150*time.Minute), 0, 10)
+	if err != nil || len(expired) != 2 || expired[0].ID != ids[0] {
+		t.Errorf("Expected the two oldest images to be expired, got %v (%v)", expired, err)
+	}
+
+	// Las borradas salen primero con cualquier politica
+	lru, _ := repo.EvictionCandidates(ctx, models.EvictLRU, 0, 10)
+	lfu, _ := repo.EvictionCandidates(ctx, models.EvictLFU, 0, 10)
+	if len(lru) != 3 || lru[0].ID != ids[2] || lru[1].ID != ids[0] {
+		t.Errorf("Unexpected LRU order: %v", lru)
+	}
+	if len(lfu) != 3 || lfu[0].ID != ids[2] || lfu[1].ID != ids[1] {
+		t.Errorf("Unexpected LFU order: %v", lfu)
+	}
+}
+
+func TestCatRepository_ConcurrentSave(t *testing.T) {
+	_, repo := setupRepository(t)
+	ctx := context.Background()
+	data := []byte("same-cat-from-many-requests")
+
+	const workers = 20
+	var (
+		wg      sync.WaitGroup
+		created atomic.Int32
+		ids     = make([]uint, workers)
+		errs    = make([]error, workers)
+	)
+	start := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			img, isNew, err := repo.Save(ctx, data, "image/jpeg", models.CatVariant{Tag: "cute"})
+			errs[i] = err
+			if err == nil {
+				ids[i] = img.ID
+				if isNew {
+					created.Add(1)
+				}
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("Save %d failed: %v", i, err)
+		}
+		if ids[i] != ids[0] {
+			t.Errorf("Expected every save to return ID %d, got %d", ids[0], ids[i])
+		}
+	}
+	if created.Load() != 1 {
+		t.Errorf("Expected exactly one save to create the image, got %d", created.Load())
+	}
+
+	// Cada Save cuenta un acceso, sin perder incrementos
+	stored, err := repo.FindByID(ctx, ids[0])
+	if err != nil {
+		t.Fatalf("Expected no error on find, got %v", err)
+	}
+	if stored.AccessCount != workers {
+		t.Errorf("Expected access count %d, got %d", workers, stored.AccessCount)
+	}
+	if stored.Variant.Tag != "cute" || stored.StorageKey == "" {
+		t.Errorf("Expected variant and storage key to be saved, got %+v", stored)
+	}
+	if count, _ := repo.CountUnique(ctx); count != 1 {
+		t.Errorf("Expected 1 unique image, got %d", count)
+	}
+}
@@ -0,0 +1,269 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/IavilaGw/cat-api/internal/handlers"
+	"github.com/IavilaGw/cat-api/internal/middleware"
+	"github.com/IavilaGw/cat-api/internal/models"
+	"github.com/IavilaGw/cat-api/internal/repositories"
+	"github.com/IavilaGw/cat-api/internal/services"
+)
+
+// memoryAPIKeyRepository guarda las claves en memoria y cuenta las
+// escrituras de last_used_at.
+type memoryAPIKeyRepository struct {
+	keys    []*models.APIKey
+	touches int
+}
+
+func (r *memoryAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	key.ID = uint(len(r.keys) + 1)
+	r.keys = append(r.keys, key)
+	return nil
+}
+
+func (r *memoryAPIKeyRepository) FindByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	for _, key := range r.keys {
+		if key.KeyHash == hash {
+			found := *key
+			return &found, nil
+		}
+	}
+	return nil, repositories.ErrAPIKeyNotFound
+}
+
+func (r *memoryAPIKeyRepository) List(ctx context.Context) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	for _, key := range r.keys {
+		keys = append(keys, *key)
+	}
+	return keys, nil
+}
+
+func (r *memoryAPIKeyRepository) Revoke(ctx context.Context, id uint) error {
+	for _, key := range r.keys {
+		if key.ID == id && key.RevokedAt == nil {
+			now := time.Now()
+			key.RevokedAt = &now
+			return nil
+		}
+	}
+	return repositories.ErrAPIKeyNotFound
+}
+
+func (r *memoryAPIKeyRepository) TouchLastUsed(ctx context.Context, id uint, at time.Time) error {
+	r.touches++
+	r.keys[id-1].LastUsedAt = &at
+	return nil
+}
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := services.ParseScopes(" read, fetch ")
+	if err != nil || len(scopes) != 2 || scopes[1] != models.ScopeFetch {
+		t.Errorf("Expected [read fetch], got %v (%v)", scopes, err)
+	}
+
+	for _, value := range []string{"", ",", "read,root"} {
+		if _, err := services.ParseScopes(value); !errors.Is(err, services.ErrInvalidScopes) {
+			t.Errorf("Expected ErrInvalidScopes for %q, got %v", value, err)
+		}
+	}
+}
+
+func TestAPIKeyService_CreateAndAuthenticate(t *testing.T) {
+	repo := &memoryAPIKeyRepository{}
+	svc := services.NewAPIKeyService(repo, discardLogger)
+	ctx := context.Background()
+
+	plaintext, key, err := svc.CreateKey(ctx, "ci", []models.APIScope{models.ScopeRead, models.ScopeWrite}, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(plaintext, key.Prefix) || key.Scopes != "read,write" || key.ExpiresAt != nil {
+		t.Errorf("Unexpected key: %+v", key)
+	}
+	if key.KeyHash == "" || strings.Contains(key.KeyHash, plaintext) {
+		t.Error("Expected only the hash of the key to be stored")
+	}
+
+	found, err := svc.Authenticate(ctx, plaintext)
+	if err != nil || found.ID != key.ID {
+		t.Fatalf("Expected the key to authenticate, got %v", err)
+	}
+	if found.LastUsedAt == nil || repo.touches != 1 {
+		t.Errorf("Expected last_used_at to be recorded once, got %d writes", repo.touches)
+	}
+
+	// Dentro del mismo minuto no se vuelve a escribir last_used_at
+	svc.Authenticate(ctx, plaintext)
+	if repo.touches != 1 {
+		t.Errorf("Expected last_used_at writes to be throttled, got %d", repo.touches)
+	}
+
+	if _, err := svc.Authenticate(ctx, plaintext+"x"); !errors.Is(err, services.ErrInvalidAPIKey) {
+		t.Errorf("Expected ErrInvalidAPIKey for an unknown key, got %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, "not-a-key"); !errors.Is(err, services.ErrInvalidAPIKey) {
+		t.Errorf("Expected ErrInvalidAPIKey for a malformed key, got %v", err)
+	}
+
+	if err := svc.RevokeKey(ctx, key.ID); err != nil {
+		t.Fatalf("Unexpected error revoking: %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, plaintext); !errors.Is(err, services.ErrInvalidAPIKey) {
+		t.Errorf("Expected a revoked key to be rejected, got %v", err)
+	}
+}
+
+func TestAPIKeyService_ExpiredKey(t *testing.T) {
+	repo := &memoryAPIKeyRepository{}
+	svc := services.NewAPIKeyService(repo, discardLogger)
+	ctx := context.Background()
+
+	plaintext, key, err := svc.CreateKey(ctx, "temporal", []models.APIScope{models.ScopeRead}, time.Hour)
+	if err != nil || key.ExpiresAt == nil {
+		t.Fatalf("Expected an expiring key, got %+v (%v)", key, err)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	repo.keys[0].ExpiresAt = &past
+	if _, err := svc.Authenticate(ctx, plaintext); !errors.Is(err, services.ErrInvalidAPIKey) {
+		t.Errorf("Expected an expired key to be rejected, got %v", err)
+	}
+}
+
+// newTestKeys crea una clave por scope en un repositorio en memoria.
+func newTestKeys(t *testing.T) (*services.APIKeyService, map[models.APIScope]string) {
+	t.Helper()
+
+	svc := services.NewAPIKeyService(&memoryAPIKeyRepository{}, discardLogger)
+	keys := map[models.APIScope]string{}
+	for _, scope := range models.AllScopes {
+		plaintext, _, err := svc.CreateKey(context.Background(), string(scope), []models.APIScope{scope}, 0)
+		if err != nil {
+			t.Fatalf("Failed to create key: %v", err)
+		}
+		keys[scope] = plaintext
+	}
+	return svc, keys
+}
+
+// newAuthRouter expone una ruta por scope; keys contiene una clave por scope.
+func newAuthRouter(t *testing.T, configure func(*middleware.APIKeyAuth)) (*gin.Engine, map[models.APIScope]string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	svc, keys := newTestKeys(t)
+	auth := middleware.NewAPIKeyAuth(svc, discardLogger)
+	if configure != nil {
+		configure(auth)
+	}
+
+	r := gin.New()
+	for _, scope := range models.AllScopes {
+		r.GET("/"+string(scope), auth.Require(scope), func(c *gin.Context) {
+			c.Status(http.StatusNoContent)
+		})
+	}
+	return r, keys
+}
+
+func authRequest(r *gin.Engine, path string, header, value string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPIKeyAuth_Require(t *testing.T) {
+	r, keys := newAuthRouter(t, nil)
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		value  string
+		want   int
+	}{
+		{"sin clave", "/read", "", "", http.StatusUnauthorized},
+		{"clave invalida", "/read", "X-API-Key", "cat_nope", http.StatusUnauthorized},
+		{"bearer", "/read", "Authorization", "Bearer " + keys[models.ScopeRead], http.StatusNoContent},
+		{"x-api-key", "/read", "X-API-Key", keys[models.ScopeRead], http.StatusNoContent},
+		{"otro esquema", "/read", "Authorization", "Basic " + keys[models.ScopeRead], http.StatusUnauthorized},
+		{"scope insuficiente", "/write", "X-API-Key", keys[models.ScopeFetch], http.StatusForbidden},
+		{"admin implica todo", "/write", "X-API-Key", keys[models.ScopeAdmin], http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := authRequest(r, tt.path, tt.header, tt.value)
+			if w.Code != tt.want {
+				t.Errorf("Expected %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected WWW-Authenticate on 401")
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuth_RequireIf(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc, keys := newTestKeys(t)
+	auth := middleware.NewAPIKeyAuth(svc, discardLogger)
+
+	r := gin.New()
+	r.GET("/image", auth.Require(models.ScopeWrite), auth.RequireIf(models.ScopeAdmin, handlers.PurgeRequested), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name string
+		path string
+		key  string
+		want int
+	}{
+		{"borrado con write", "/image", keys[models.ScopeWrite], http.StatusNoContent},
+		{"purge con write", "/image?purge=true", keys[models.ScopeWrite], http.StatusForbidden},
+		{"purge=false con write", "/image?purge=false", keys[models.ScopeWrite], http.StatusNoContent},
+		{"purge con admin", "/image?purge=true", keys[models.ScopeAdmin], http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := authRequest(r, tt.path, "X-API-Key", tt.key); w.Code != tt.want {
+				t.Errorf("Expected %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuth_PublicReadAndDisabled(t *testing.T) {
+	r, keys := newAuthRouter(t, func(a *middleware.APIKeyAuth) { a.WithPublicRead(true) })
+
+	if w := authRequest(r, "/read", "", ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected public read, got %d", w.Code)
+	}
+	if w := authRequest(r, "/fetch", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected fetch to still need a key, got %d", w.Code)
+	}
+	// Una clave enviada se valida aunque la ruta sea publica
+	if w := authRequest(r, "/read", "X-API-Key", keys[models.ScopeRead]+"x"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a bad key to be rejected, got %d", w.Code)
+	}
+
+	r, _ = newAuthRouter(t, func(a *middleware.APIKeyAuth) { a.WithDisabled(true) })
+	if w := authRequest(r, "/admin", "", ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected no auth when disabled, got %d", w.Code)
+	}
+}
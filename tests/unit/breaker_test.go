@@ -0,0 +1,158 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IavilaGw/cat-api/internal/breaker"
+	"github.com/IavilaGw/cat-api/internal/services"
+	"github.com/IavilaGw/cat-api/pkg/client"
+)
+
+var testBreakerConfig = breaker.Config{
+	FailureRate:    0.5,
+	MinRequests:    4,
+	Window:         time.Minute,
+	CoolDown:       30 * time.Millisecond,
+	HalfOpenProbes: 1,
+}
+
+// tripBreaker abre b con MinRequests fallos seguidos.
+func tripBreaker(b *breaker.Breaker) {
+	for i := 0; i < testBreakerConfig.MinRequests; i++ {
+		permit, _ := b.Allow()
+		b.Record(permit, false)
+	}
+}
+
+func TestBreaker_OpensOnFailureRate(t *testing.T) {
+	b := breaker.New(testBreakerConfig)
+
+	for _, ok := range []bool{true, false, true} {
+		permit, err := b.Allow()
+		if err != nil {
+			t.Fatalf("Expected closed breaker to allow, got %v", err)
+		}
+		b.Record(permit, ok)
+	}
+	if b.State() != breaker.Closed {
+		t.Fatalf("Expected closed below MinRequests, got %s", b.State())
+	}
+
+	permit, _ := b.Allow()
+	b.Record(permit, false)
+
+	if b.State() != breaker.Open {
+		t.Fatalf("Expected open at 50%% failures, got %s", b.State())
+	}
+	if _, err := b.Allow(); !errors.Is(err, breaker.ErrOpen) {
+		t.Errorf("Expected ErrOpen, got %v", err)
+	}
+}
+
+func TestBreaker_HalfOpenRecovery(t *testing.T) {
+	b := breaker.New(testBreakerConfig)
+	tripBreaker(b)
+
+	time.Sleep(40 * time.Millisecond)
+
+	if b.State() != breaker.HalfOpen {
+		t.Fatalf("Expected half-open after cool-down, got %s", b.State())
+	}
+	probe, err := b.Allow()
+	if err != nil {
+		t.Fatalf("Expected probe to be allowed, got %v", err)
+	}
+	if _, err := b.Allow(); !errors.Is(err, breaker.ErrOpen) {
+		t.Errorf("Expected only one probe in flight, got %v", err)
+	}
+
+	b.Record(probe, true)
+	if b.State() != breaker.Closed {
+		t.Errorf("Expected closed after successful probe, got %s", b.State())
+	}
+}
+
+func TestBreaker_HalfOpenFailureReopens(t *testing.T) {
+	b := breaker.New(testBreakerConfig)
+	tripBreaker(b)
+
+	time.Sleep(40 * time.Millisecond)
+	probe, _ := b.Allow()
+	b.Record(probe, false)
+
+	if b.State() != breaker.Open {
+		t.Errorf("Expected open after failed probe, got %s", b.State())
+	}
+}
+
+func TestBreaker_IgnoresStalePermits(t *testing.T) {
+	b := breaker.New(testBreakerConfig)
+	slow, _ := b.Allow()
+	tripBreaker(b)
+
+	time.Sleep(40 * time.Millisecond)
+	if _, err := b.Allow(); err != nil {
+		t.Fatalf("Expected probe to be allowed, got %v", err)
+	}
+
+	// Una llamada admitida antes de abrir no libera el lugar de la prueba
+	b.Record(slow, true)
+	if _, err := b.Allow(); !errors.Is(err, breaker.ErrOpen) {
+		t.Errorf("Expected the probe slot to stay taken, got %v", err)
+	}
+	b.Release(slow)
+	if _, err := b.Allow(); !errors.Is(err, breaker.ErrOpen) {
+		t.Errorf("Expected the probe slot to stay taken after a stale release, got %v", err)
+	}
+
+	// Ni su fallo vuelve a abrir el breaker
+	b.Record(slow, false)
+	if b.State() != breaker.HalfOpen {
+		t.Errorf("Expected half-open, got %s", b.State())
+	}
+}
+
+func TestCircuitBreakerClient_FailsFast(t *testing.T) {
+	calls := 0
+	mockClient := &MockCataasClient{
+		GetRandomCatFunc: func(ctx context.Context, opts services.CatOptions) (*services.CatImageResponse, error) {
+			calls++
+			return nil, &client.UpstreamError{StatusCode: 502, Attempts: 1, Err: errors.New("bad gateway")}
+		},
+	}
+
+	wrapped := services.NewCircuitBreakerClient(mockClient, breaker.New(testBreakerConfig))
+	for i := 0; i < 10; i++ {
+		wrapped.GetRandomCat(context.Background(), services.CatOptions{})
+	}
+
+	if calls != 4 {
+		t.Errorf("Expected upstream to be called 4 times before opening, got %d", calls)
+	}
+
+	service := services.NewCatService(&MockCatRepository{}, wrapped, discardLogger)
+	if _, err := service.FetchAndSaveRandomCat(context.Background(), services.CatOptions{}); !errors.Is(err, breaker.ErrOpen) {
+		t.Errorf("Expected ErrOpen from service, got %v", err)
+	}
+}
+
+func TestCircuitBreakerClient_IgnoresClientErrors(t *testing.T) {
+	mockClient := &MockCataasClient{
+		GetRandomCatFunc: func(ctx context.Context, opts services.CatOptions) (*services.CatImageResponse, error) {
+			return nil, &client.UpstreamError{StatusCode: 404, Attempts: 1, Err: errors.New("unknown tag")}
+		},
+	}
+
+	b := breaker.New(testBreakerConfig)
+	wrapped := services.NewCircuitBreakerClient(mockClient, b)
+	for i := 0; i < 10; i++ {
+		wrapped.GetRandomCat(context.Background(), services.CatOptions{Tag: "nope"})
+	}
+
+	if b.State() != breaker.Closed {
+		t.Errorf("Expected 404s to keep the breaker closed, got %s", b.State())
+	}
+}
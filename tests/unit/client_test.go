@@ -0,0 +1,79 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/IavilaGw/cat-api/pkg/client"
+)
+
+func TestCataasClient_GetRandomCatOptions(t *testing.T) {
+	cases := []struct {
+		opts      client.CatOptions
+		wantPath  string
+		wantQuery string
+	}{
+		{client.CatOptions{}, "/cat", ""},
+		{client.CatOptions{Tag: "cute"}, "/cat/cute", ""},
+		{client.CatOptions{Says: "hello world"}, "/cat/says/hello world", ""},
+		{client.CatOptions{Tag: "orange", Says: "hi"}, "/cat/orange/says/hi", ""},
+		{client.CatOptions{Filter: "mono", Width: 100, Height: 50, Type: "square"}, "/cat", "filter=mono&height=50&type=square&width=100"},
+	}
+
+	for _, tc := range cases {
+		var gotPath, gotQuery string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotPath = r.URL.Path
+			gotQuery = r.URL.RawQuery
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("png"))
+		}))
+
+		c := client.NewCataasClient(server.URL, 5)
+		resp, err := c.GetRandomCat(context.Background(), tc.opts)
+		server.Close()
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if gotPath != tc.wantPath {
+			t.Errorf("Expected path %q, got %q", tc.wantPath, gotPath)
+		}
+		if gotQuery != tc.wantQuery {
+			t.Errorf("Expected query %q, got %q", tc.wantQuery, gotQuery)
+		}
+		if resp.ContentType != "image/png" {
+			t.Errorf("Expected image/png, got %s", resp.ContentType)
+		}
+	}
+}
+
+func TestCataasClient_GetRandomCatCancelled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	c := client.NewCataasClient(server.URL, 30)
+	start := time.Now()
+	_, err := c.GetRandomCat(ctx, client.CatOptions{})
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("Expected request to stop when the context was cancelled")
+	}
+}
@@ -0,0 +1,216 @@
+package services_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/IavilaGw/cat-api/internal/config"
+)
+
+// writeFile crea un archivo temporal con el contenido dado y devuelve su ruta.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func settingFor(t *testing.T, cfg *config.Config, key string) config.Setting {
+	t.Helper()
+	for _, setting := range cfg.Settings() {
+		if setting.Key == key {
+			return setting
+		}
+	}
+	t.Fatalf("Expected a setting for %s", key)
+	return config.Setting{}
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatalf("Expected the defaults to be valid, got %v", err)
+	}
+	if cfg.App.TimeoutSeconds != 30 || cfg.Server.Port != "8080" {
+		t.Errorf("Unexpected defaults: timeout %d, port %s", cfg.App.TimeoutSeconds, cfg.Server.Port)
+	}
+	if s := settingFor(t, cfg, "TIMEOUT_SECONDS"); s.Source != config.SourceDefault || s.Value != "30" {
+		t.Errorf("Expected TIMEOUT_SECONDS=30 from default, got %+v", s)
+	}
+}
+
+func TestLoad_YAMLUnderEnv(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+server:
+  port: 9000
+db:
+  host: db.internal
+  port: 6543
+cataas_retry_jitter: 0.5
+retention:
+  max_age: 720h
+cors:
+  allowed_origins: [https://a.example.com, "https://*.b.example.com"]
+`)
+	t.Setenv("DB_HOST", "from-env")
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if cfg.Server.Port != "9000" || cfg.Database.Port != "6543" || cfg.App.Retry.Jitter != 0.5 {
+		t.Errorf("Expected values from the file, got %+v", cfg)
+	}
+	if cfg.App.Retention.MaxAge.Hours() != 720 {
+		t.Errorf("Expected RETENTION_MAX_AGE 720h, got %s", cfg.App.Retention.MaxAge)
+	}
+	if len(cfg.CORS.AllowedOrigins) != 2 || cfg.CORS.AllowedOrigins[1] != "https://*.b.example.com" {
+		t.Errorf("Expected the origins list from the file, got %v", cfg.CORS.AllowedOrigins)
+	}
+
+	// El entorno gana sobre el archivo
+	if cfg.Database.Host != "from-env" {
+		t.Errorf("Expected env to override the file, got %s", cfg.Database.Host)
+	}
+	if s := settingFor(t, cfg, "DB_HOST"); s.Source != config.SourceEnv {
+		t.Errorf("Expected DB_HOST from env, got %+v", s)
+	}
+	if s := settingFor(t, cfg, "SERVER_PORT"); s.Source != path {
+		t.Errorf("Expected SERVER_PORT from %s, got %+v", path, s)
+	}
+}
+
+func TestLoad_TOML(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+gin_mode = "debug"
+
+[rate_limit]
+backend = "none"
+fetch = "5/m"
+fetch_burst = 2
+
+[auth]
+public_read = true
+`)
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.Server.Mode != "debug" || cfg.RateLimit.Backend != "none" || !cfg.Auth.PublicRead {
+		t.Errorf("Expected values from the file, got %+v", cfg)
+	}
+	if cfg.RateLimit.Fetch != (config.RateLimitRule{Rate: "5/m", Burst: 2}) {
+		t.Errorf("Expected fetch 5/m burst 2, got %+v", cfg.RateLimit.Fetch)
+	}
+}
+
+func TestLoad_ReportsEveryError(t *testing.T) {
+	path := writeFile(t, "config.yaml", "server:\n  portt: 8081\n")
+	t.Setenv("TIMEOUT_SECONDS", "abc")
+	t.Setenv("GIN_MODE", "production")
+	t.Setenv("SERVER_PORT", "70000")
+	t.Setenv("CATAAS_API_URL", "cataas.com")
+	t.Setenv("RETENTION_POLICY", "fifo")
+	t.Setenv("RATE_LIMIT_FETCH", "30/d")
+	t.Setenv("TLS_CLIENT_AUTH", "none")
+
+	cfg, err := config.Load(path)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if cfg == nil {
+		t.Fatal("Expected the config to be returned along with validation errors")
+	}
+
+	// Antes TIMEOUT_SECONDS invalido volvia a 30 sin avisar
+	for _, want := range []string{"TIMEOUT_SECONDS", "GIN_MODE", "SERVER_PORT:", "CATAAS_API_URL", "RETENTION_POLICY", "RATE_LIMIT_FETCH", "TLS_CLIENT_AUTH", "SERVER_PORTT"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected the error to mention %s, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoad_SecretFiles(t *testing.T) {
+	secret := writeFile(t, "db_password", "s3cr3t\n")
+	t.Setenv("DB_PASSWORD_FILE", secret)
+
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.Database.Password != "s3cr3t" {
+		t.Errorf("Expected the password from the file without the newline, got %q", cfg.Database.Password)
+	}
+	if s := settingFor(t, cfg, "DB_PASSWORD"); !s.Secret || !strings.Contains(s.Source, "DB_PASSWORD_FILE") {
+		t.Errorf("Expected a secret setting read from DB_PASSWORD_FILE, got %+v", s)
+	}
+
+	// La variable y su _FILE a la vez es ambiguo
+	t.Setenv("DB_PASSWORD", "other")
+	if _, err := config.Load(""); err == nil || !strings.Contains(err.Error(), "DB_PASSWORD_FILE") {
+		t.Errorf("Expected an error for DB_PASSWORD and DB_PASSWORD_FILE, got %v", err)
+	}
+}
+
+func TestLoad_SecretFileFromConfigFile(t *testing.T) {
+	secret := writeFile(t, "s3_secret", "minio-secret")
+	path := writeFile(t, "config.yml", "s3:\n  secret_key_file: "+secret+"\n")
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.App.BlobStore.S3SecretKey != "minio-secret" {
+		t.Errorf("Expected the S3 secret from the file, got %q", cfg.App.BlobStore.S3SecretKey)
+	}
+}
+
+func TestLoad_EmptyListClearsDefault(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "")
+
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(cfg.CORS.AllowedOrigins) != 0 {
+		t.Errorf("Expected no allowed origins, got %v", cfg.CORS.AllowedOrigins)
+	}
+}
+
+func TestLoad_BadConfigFile(t *testing.T) {
+	if _, err := config.Load(writeFile(t, "config.json", "{}")); err == nil {
+		t.Error("Expected an error for an unsupported extension")
+	}
+	if _, err := config.Load(writeFile(t, "config.yaml", "server: [")); err == nil {
+		t.Error("Expected an error for invalid YAML")
+	}
+	if _, err := config.Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestValidate_TLSAndCORS(t *testing.T) {
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	cfg.Server.TLS.CertFile = "cert.pem"
+	cfg.Server.TLS.ClientAuth = "optional"
+	cfg.CORS.AllowCredentials = true
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected validation errors")
+	}
+	for _, want := range []string{"TLS_KEY_FILE", "TLS_CLIENT_CA_FILE", "CORS_ALLOW_CREDENTIALS"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected the error to mention %s, got:\n%v", want, err)
+		}
+	}
+}
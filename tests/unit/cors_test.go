@@ -0,0 +1,167 @@
+package services_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/IavilaGw/cat-api/internal/middleware"
+)
+
+// newCORSRouter registra las rutas despues del middleware, como main.
+func newCORSRouter(t *testing.T, policy middleware.CORSPolicy) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cors, err := middleware.NewCORS(policy)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	r := gin.New()
+	r.Use(cors.Handler(r))
+	ok := func(c *gin.Context) {
+		c.Header("X-Image-ID", "1")
+		c.Status(http.StatusOK)
+	}
+	r.GET("/api/images", ok)
+	r.POST("/api/images", ok)
+	r.GET("/api/image/:id", ok)
+	r.HEAD("/api/image/:id", ok)
+	r.DELETE("/api/image/:id", ok)
+	return r
+}
+
+var testCORSPolicy = middleware.CORSPolicy{
+	AllowedOrigins:   []string{"https://cats.example.org", "https://*.example.com"},
+	AllowedHeaders:   []string{"Authorization", "X-API-Key"},
+	ExposedHeaders:   []string{"X-Image-ID", "X-Image-Hash"},
+	AllowCredentials: true,
+	MaxAge:           10 * time.Minute,
+}
+
+func corsRequest(r *gin.Engine, method, path, origin, requestMethod string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if requestMethod != "" {
+		req.Header.Set("Access-Control-Request-Method", requestMethod)
+		req.Header.Set("Access-Control-Request-Headers", "x-api-key")
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	r := newCORSRouter(t, testCORSPolicy)
+
+	w := corsRequest(r, http.MethodOptions, "/api/image/42", "https://cats.example.org", http.MethodDelete)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d", w.Code)
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":      "https://cats.example.org",
+		"Access-Control-Allow-Methods":     "DELETE, GET, HEAD",
+		"Access-Control-Allow-Headers":     "Authorization, X-API-Key",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Max-Age":           "600",
+	}
+	for header, value := range want {
+		if got := w.Header().Get(header); got != value {
+			t.Errorf("Expected %s %q, got %q", header, value, got)
+		}
+	}
+	vary := strings.Join(w.Header().Values("Vary"), ", ")
+	for _, v := range []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"} {
+		if !strings.Contains(vary, v) {
+			t.Errorf("Expected Vary to include %s, got %q", v, vary)
+		}
+	}
+
+	// Los metodos salen de las rutas registradas para ese path
+	w = corsRequest(r, http.MethodOptions, "/api/images", "https://cats.example.org", http.MethodPost)
+	if got := w.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST" {
+		t.Errorf("Expected GET, POST for /api/images, got %q", got)
+	}
+}
+
+func TestCORS_PreflightRejections(t *testing.T) {
+	r := newCORSRouter(t, testCORSPolicy)
+
+	tests := []struct {
+		name   string
+		path   string
+		origin string
+		method string
+		want   int
+	}{
+		{"subdominio por patron", "/api/images", "https://app.example.com", http.MethodGet, http.StatusNoContent},
+		{"origen no permitido", "/api/images", "https://evil.test", http.MethodGet, http.StatusForbidden},
+		{"sufijo enganoso", "/api/images", "https://example.com.evil.test", http.MethodGet, http.StatusForbidden},
+		{"metodo no registrado", "/api/images", "https://cats.example.org", http.MethodPut, http.StatusForbidden},
+		{"ruta inexistente", "/api/dogs", "https://cats.example.org", http.MethodGet, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := corsRequest(r, http.MethodOptions, tt.path, tt.origin, tt.method)
+			if w.Code != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, w.Code)
+			}
+			if tt.want != http.StatusNoContent && w.Header().Get("Access-Control-Allow-Origin") != "" {
+				t.Error("Expected no Access-Control-Allow-Origin on a rejected preflight")
+			}
+		})
+	}
+}
+
+func TestCORS_SimpleRequests(t *testing.T) {
+	r := newCORSRouter(t, testCORSPolicy)
+
+	w := corsRequest(r, http.MethodGet, "/api/image/1", "https://cats.example.org", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://cats.example.org" ||
+		w.Header().Get("Access-Control-Expose-Headers") != "X-Image-ID, X-Image-Hash" ||
+		w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("Unexpected CORS headers: %v", w.Header())
+	}
+	if w.Header().Get("Vary") != "Origin" {
+		t.Errorf("Expected Vary: Origin, got %q", w.Header().Get("Vary"))
+	}
+
+	// Un origen no permitido recibe la respuesta, pero sin cabeceras CORS
+	w = corsRequest(r, http.MethodGet, "/api/image/1", "https://evil.test", "")
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected no CORS headers for a foreign origin, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestCORS_Wildcard(t *testing.T) {
+	r := newCORSRouter(t, middleware.CORSPolicy{AllowedOrigins: []string{"*"}})
+
+	w := corsRequest(r, http.MethodGet, "/api/images", "https://anywhere.test", "")
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" || w.Header().Get("Vary") != "" {
+		t.Errorf("Expected a wildcard without Vary, got %v", w.Header())
+	}
+
+	w = corsRequest(r, http.MethodOptions, "/api/images", "https://anywhere.test", http.MethodGet)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Max-Age") != "" {
+		t.Errorf("Expected 204 without Max-Age, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestNewCORS_InvalidPolicies(t *testing.T) {
+	if _, err := middleware.NewCORS(middleware.CORSPolicy{AllowedOrigins: []string{"*"}, AllowCredentials: true}); err == nil {
+		t.Error("Expected an error for credentials with a wildcard origin")
+	}
+	if _, err := middleware.NewCORS(middleware.CORSPolicy{AllowedOrigins: []string{"https://[.example.com"}}); err == nil {
+		t.Error("Expected an error for a malformed pattern")
+	}
+}
@@ -0,0 +1,139 @@
+package services_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/IavilaGw/cat-api/internal/handlers"
+	"github.com/IavilaGw/cat-api/internal/models"
+	"github.com/IavilaGw/cat-api/internal/services"
+)
+
+var cachedImageCreatedAt = time.Date(2024, 5, 1, 12, 30, 45, 500, time.UTC)
+
+type nopReadSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopReadSeekCloser) Close() error { return nil }
+
+// newImageRouter sirve /api/image/:id sobre un repositorio falso con una sola
+// imagen; accesses cuenta las llamadas a RecordAccess y loads las lecturas.
+func newImageRouter(accesses, loads *int32, data []byte) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	mockRepo := &MockCatRepository{
+		FindByIDFunc: func(ctx context.Context, id uint) (*models.CatImage, error) {
+			return &models.CatImage{
+				ID:          id,
+				ImageHash:   "abc123",
+				ContentType: "image/jpeg",
+				Size:        int64(len(data)),
+				CreatedAt:   cachedImageCreatedAt,
+			}, nil
+		},
+		RecordAccessFunc: func(ctx context.Context, catImage *models.CatImage) error {
+			atomic.AddInt32(accesses, 1)
+			return nil
+		},
+		OpenDataFunc: func(ctx context.Context, catImage *models.CatImage) (io.ReadSeekCloser, error) {
+			atomic.AddInt32(loads, 1)
+			return nopReadSeekCloser{bytes.NewReader(data)}, nil
+		},
+	}
+	service := services.NewCatService(mockRepo, &MockCataasClient{}, discardLogger)
+	handler := handlers.NewCatHandler(service, discardLogger)
+
+	r := gin.New()
+	r.GET("/api/image/:id", handler.GetImageByID)
+	r.GET("/api/image/:id/meta", handler.GetImageMeta)
+	return r
+}
+
+func TestGetImageByID_CacheHeaders(t *testing.T) {
+	var accesses, loads int32
+	r := newImageRouter(&accesses, &loads, []byte("cat-bytes"))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/image/1", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("ETag"); got != `"abc123"` {
+		t.Errorf("Expected ETag from image hash, got %q", got)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "public, max-age=31536000, immutable" {
+		t.Errorf("Unexpected Cache-Control %q", got)
+	}
+	if got := w.Header().Get("Last-Modified"); got != "Wed, 01 May 2024 12:30:45 GMT" {
+		t.Errorf("Unexpected Last-Modified %q", got)
+	}
+}
+
+func TestGetImageByID_ConditionalRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{"etag coincide", "If-None-Match", `"abc123"`, http.StatusNotModified},
+		{"etag debil coincide", "If-None-Match", `W/"abc123"`, http.StatusNotModified},
+		{"etag en lista", "If-None-Match", `"other", "abc123"`, http.StatusNotModified},
+		{"comodin", "If-None-Match", "*", http.StatusNotModified},
+		{"etag distinto", "If-None-Match", `"other"`, http.StatusOK},
+		{"no modificado desde", "If-Modified-Since", "Wed, 01 May 2024 12:30:45 GMT", http.StatusNotModified},
+		{"modificado desde", "If-Modified-Since", "Wed, 01 May 2024 12:30:44 GMT", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var accesses, loads int32
+			r := newImageRouter(&accesses, &loads, []byte("cat-bytes"))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/image/1", nil)
+			req.Header.Set(tt.header, tt.value)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("Expected %d, got %d", tt.want, w.Code)
+			}
+			// El acceso se cuenta siempre, pero en un 304 no se leen los bytes
+			if accesses != 1 {
+				t.Errorf("Expected one recorded access, got %d", accesses)
+			}
+			if tt.want == http.StatusNotModified {
+				if loads != 0 || w.Body.Len() != 0 {
+					t.Errorf("Expected no body on 304 (loads=%d, body=%d bytes)", loads, w.Body.Len())
+				}
+				if w.Header().Get("ETag") != `"abc123"` {
+					t.Error("Expected ETag on 304 response")
+				}
+			}
+		})
+	}
+}
+
+func TestGetImageByID_IfNoneMatchWinsOverIfModifiedSince(t *testing.T) {
+	var accesses, loads int32
+	r := newImageRouter(&accesses, &loads, []byte("cat-bytes"))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/image/1", nil)
+	req.Header.Set("If-None-Match", `"other"`)
+	req.Header.Set("If-Modified-Since", "Wed, 01 May 2024 12:30:45 GMT")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 when the ETag does not match, got %d", w.Code)
+	}
+}
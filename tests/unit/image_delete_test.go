@@ -0,0 +1,99 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/IavilaGw/cat-api/internal/handlers"
+	"github.com/IavilaGw/cat-api/internal/models"
+	"github.com/IavilaGw/cat-api/internal/services"
+)
+
+// newDeleteRouter simula un repositorio con las imagenes 1 (activa) y 2
+// (borrada); calls registra que operacion se ejecuto.
+func newDeleteRouter(calls *[]string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	exists := func(id uint) bool { return id == 1 || id == 2 }
+	mockRepo := &MockCatRepository{
+		SoftDeleteFunc: func(ctx context.Context, id uint) error {
+			*calls = append(*calls, fmt.Sprintf("delete %d", id))
+			if id != 1 {
+				return services.ErrImageNotFound
+			}
+			return nil
+		},
+		RestoreFunc: func(ctx context.Context, id uint) (*models.CatImage, error) {
+			*calls = append(*calls, fmt.Sprintf("restore %d", id))
+			if !exists(id) {
+				return nil, services.ErrImageNotFound
+			}
+			return &models.CatImage{ID: id, ImageHash: "abc"}, nil
+		},
+		PurgeFunc: func(ctx context.Context, id uint) error {
+			*calls = append(*calls, fmt.Sprintf("purge %d", id))
+			if !exists(id) {
+				return services.ErrImageNotFound
+			}
+			return nil
+		},
+	}
+	handler := handlers.NewCatHandler(services.NewCatService(mockRepo, &MockCataasClient{}, discardLogger), discardLogger)
+
+	r := gin.New()
+	r.DELETE("/api/image/:id", handler.DeleteImage)
+	r.POST("/api/image/:id/restore", handler.RestoreImage)
+	return r
+}
+
+func TestDeleteRestorePurge(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   int
+		call   string
+	}{
+		{http.MethodDelete, "/api/image/1", http.StatusNoContent, "delete 1"},
+		{http.MethodDelete, "/api/image/2", http.StatusNotFound, "delete 2"},
+		{http.MethodDelete, "/api/image/2?purge=true", http.StatusNoContent, "purge 2"},
+		{http.MethodDelete, "/api/image/9?purge=true", http.StatusNotFound, "purge 9"},
+		{http.MethodPost, "/api/image/2/restore", http.StatusOK, "restore 2"},
+		{http.MethodPost, "/api/image/9/restore", http.StatusNotFound, "restore 9"},
+		{http.MethodDelete, "/api/image/abc", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		var calls []string
+		r := newDeleteRouter(&calls)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if w.Code != tt.want {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.want, w.Code)
+		}
+		if tt.call != "" && (len(calls) != 1 || calls[0] != tt.call) {
+			t.Errorf("%s %s: expected %q, got %v", tt.method, tt.path, tt.call, calls)
+		}
+	}
+}
+
+func TestUploadImage_DeletedImage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockRepo := &MockCatRepository{
+		SaveFunc: func(ctx context.Context, data []byte, contentType string, variant models.CatVariant) (*models.CatImage, bool, error) {
+			return &models.CatImage{ID: 4}, false, services.ErrImageDeleted
+		},
+	}
+	handler := handlers.NewCatHandler(services.NewCatService(mockRepo, &MockCataasClient{}, discardLogger), discardLogger)
+	r := gin.New()
+	r.POST("/api/images", handler.UploadImage)
+
+	if w := postRaw(r, encodeTestImage(t, "png", 4, 4), "image/png"); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a deleted image, got %d", w.Code)
+	}
+}
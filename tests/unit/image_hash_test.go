@@ -0,0 +1,151 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/IavilaGw/cat-api/internal/handlers"
+	"github.com/IavilaGw/cat-api/internal/models"
+	"github.com/IavilaGw/cat-api/internal/services"
+)
+
+var knownHash = strings.Repeat("ab", 32)
+
+// newHashRouter registra las rutas de imagenes igual que main.
+func newHashRouter(accesses, loads *int32) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	image := &models.CatImage{ID: 9, ImageHash: knownHash, ContentType: "image/gif", Size: 3}
+	mockRepo := &MockCatRepository{
+		FindByIDFunc: func(ctx context.Context, id uint) (*models.CatImage, error) {
+			return image, nil
+		},
+		FindByHashFunc: func(ctx context.Context, hash string) (*models.CatImage, error) {
+			if hash == strings.Repeat("f", 64) {
+				return nil, errors.New("connection refused")
+			}
+			if hash != knownHash {
+				return nil, services.ErrImageNotFound
+			}
+			return image, nil
+		},
+		RecordAccessFunc: func(ctx context.Context, catImage *models.CatImage) error {
+			*accesses++
+			return nil
+		},
+		OpenDataFunc: func(ctx context.Context, catImage *models.CatImage) (io.ReadSeekCloser, error) {
+			*loads++
+			return nopReadSeekCloser{strings.NewReader("gif")}, nil
+		},
+	}
+	handler := handlers.NewCatHandler(services.NewCatService(mockRepo, &MockCataasClient{}, discardLogger), discardLogger)
+
+	r := gin.New()
+	api := r.Group("/api")
+	api.GET("/image/:id", handler.GetImageByID)
+	api.HEAD("/image/:id", handler.GetImageByID)
+	api.GET("/image/:id/meta", handler.GetImageMeta)
+	api.GET("/image/hash/:sha256", handler.GetImageByHash)
+	api.HEAD("/image/hash/:sha256", handler.GetImageByHash)
+	return r
+}
+
+func TestGetImageByHash(t *testing.T) {
+	var accesses, loads int32
+	r := newHashRouter(&accesses, &loads)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/image/hash/"+strings.ToUpper(knownHash), nil))
+
+	if w.Code != http.StatusOK || w.Body.String() != "gif" {
+		t.Fatalf("Expected the image, got %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("ETag") != `"`+knownHash+`"` {
+		t.Errorf("Unexpected ETag %q", w.Header().Get("ETag"))
+	}
+	if accesses != 1 {
+		t.Errorf("Expected one recorded access, got %d", accesses)
+	}
+}
+
+func TestGetImageByHash_Errors(t *testing.T) {
+	var accesses, loads int32
+	r := newHashRouter(&accesses, &loads)
+
+	tests := map[string]int{
+		"/api/image/hash/not-a-hash":                 http.StatusBadRequest,
+		"/api/image/hash/" + strings.Repeat("0", 64): http.StatusNotFound,
+		"/api/image/hash/" + strings.Repeat("f", 64): http.StatusInternalServerError,
+		"/api/image/hash/" + strings.Repeat("a", 63): http.StatusBadRequest,
+		"/api/image/hash/" + strings.Repeat("g", 64): http.StatusBadRequest,
+	}
+	for path, want := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != want {
+			t.Errorf("%s: expected %d, got %d", path, want, w.Code)
+		}
+	}
+}
+
+func TestHeadImage_DoesNotReadOrCount(t *testing.T) {
+	for _, path := range []string{"/api/image/hash/" + knownHash, "/api/image/9"} {
+		var accesses, loads int32
+		r := newHashRouter(&accesses, &loads)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodHead, path, nil))
+
+		if w.Code != http.StatusOK || w.Body.Len() != 0 {
+			t.Errorf("%s: expected empty 200, got %d (%d bytes)", path, w.Code, w.Body.Len())
+		}
+		if w.Header().Get("Content-Length") != "3" || w.Header().Get("Content-Type") != "image/gif" {
+			t.Errorf("%s: unexpected headers %v", path, w.Header())
+		}
+		if accesses != 0 || loads != 0 {
+			t.Errorf("%s: expected no access and no read, got %d accesses and %d loads", path, accesses, loads)
+		}
+	}
+}
+
+func TestHeadImage_NotFound(t *testing.T) {
+	var accesses, loads int32
+	r := newHashRouter(&accesses, &loads)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/api/image/hash/"+strings.Repeat("0", 64), nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", w.Code)
+	}
+}
+
+func TestGetRandomCat_ContentLocation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockRepo := &MockCatRepository{
+		SaveFunc: func(ctx context.Context, data []byte, contentType string, variant models.CatVariant) (*models.CatImage, bool, error) {
+			return &models.CatImage{ID: 1, ImageHash: knownHash, ContentType: contentType}, true, nil
+		},
+	}
+	mockClient := &MockCataasClient{
+		GetRandomCatFunc: func(ctx context.Context, opts services.CatOptions) (*services.CatImageResponse, error) {
+			return &services.CatImageResponse{Data: []byte("cat"), ContentType: "image/jpeg", Size: 3, Attempts: 1}, nil
+		},
+	}
+	handler := handlers.NewCatHandler(services.NewCatService(mockRepo, mockClient, discardLogger), discardLogger)
+
+	r := gin.New()
+	r.GET("/api/cat", handler.GetRandomCat)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/cat", nil))
+
+	if got := w.Header().Get("Content-Location"); got != "/api/image/hash/"+knownHash {
+		t.Errorf("Expected hash-based Content-Location, got %q", got)
+	}
+}
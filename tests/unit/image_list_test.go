@@ -0,0 +1,251 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/IavilaGw/cat-api/internal/handlers"
+	"github.com/IavilaGw/cat-api/internal/models"
+	"github.com/IavilaGw/cat-api/internal/services"
+)
+
+// fakeListRepo implementa List en memoria con la misma semantica de keyset
+// que el repositorio real.
+func fakeListRepo(images []models.CatImage, queries *[]models.ImageListQuery) *MockCatRepository {
+	return &MockCatRepository{
+		ListFunc: func(ctx context.Context, q models.ImageListQuery) ([]models.CatImage, error) {
+			if queries != nil {
+				*queries = append(*queries, q)
+			}
+
+			key := func(img models.CatImage) (int64, uint) {
+				c := models.CursorFor(&img, q.Sort)
+				if q.Sort.IsTime() {
+					return c.Time.UnixNano(), c.ID
+				}
+				return c.Num, c.ID
+			}
+			less := func(a, b models.CatImage) bool {
+				av, aid := key(a)
+				bv, bid := key(b)
+				if av != bv {
+					return av < bv
+				}
+				return aid < bid
+			}
+
+			sorted := append([]models.CatImage(nil), images...)
+			sort.Slice(sorted, func(i, j int) bool {
+				if q.Descending {
+					return less(sorted[j], sorted[i])
+				}
+				return less(sorted[i], sorted[j])
+			})
+
+			var out []models.CatImage
+			for _, img := range sorted {
+				if q.ContentType != "" && img.ContentType != q.ContentType {
+					continue
+				}
+				if q.After != nil {
+					after := models.CatImage{ID: q.After.ID, CreatedAt: q.After.Time, LastAccessedAt: q.After.Time, AccessCount: int(q.After.Num), Size: q.After.Num}
+					if q.Descending && !less(img, after) || !q.Descending && !less(after, img) {
+						continue
+					}
+				}
+				out = append(out, img)
+				if len(out) == q.Limit {
+					break
+				}
+			}
+			return out, nil
+		},
+	}
+}
+
+func sampleImages() []models.CatImage {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var images []models.CatImage
+	for i := 1; i <= 7; i++ {
+		contentType := "image/jpeg"
+		if i%2 == 0 {
+			contentType = "image/gif"
+		}
+		images = append(images, models.CatImage{
+			ID:          uint(i),
+			ContentType: contentType,
+			Size:        int64(100 * (i % 3)),
+			AccessCount: i,
+			CreatedAt:   base.Add(time.Duration(i) * time.Hour),
+		})
+	}
+	return images
+}
+
+func TestListImages_PaginatesWithCursor(t *testing.T) {
+	service := services.NewCatService(fakeListRepo(sampleImages(), nil), &MockCataasClient{}, discardLogger)
+	ctx := context.Background()
+
+	var ids []uint
+	opts := services.ListImagesOptions{Limit: 3}
+	pages := 0
+	for {
+		page, err := service.ListImages(ctx, opts)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		pages++
+		for _, img := range page.Images {
+			ids = append(ids, img.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+
+	if pages != 3 {
+		t.Errorf("Expected 3 pages, got %d", pages)
+	}
+	want := []uint{7, 6, 5, 4, 3, 2, 1}
+	if len(ids) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, ids)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("Expected %v (newest first), got %v", want, ids)
+		}
+	}
+}
+
+func TestListImages_SortByTiedValues(t *testing.T) {
+	// size se repite (0, 100, 200), el ID desempata sin saltar ni repetir filas
+	service := services.NewCatService(fakeListRepo(sampleImages(), nil), &MockCataasClient{}, discardLogger)
+
+	seen := map[uint]bool{}
+	opts := services.ListImagesOptions{Limit: 2, Sort: "size", Order: "asc"}
+	for {
+		page, err := service.ListImages(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		for _, img := range page.Images {
+			if seen[img.ID] {
+				t.Fatalf("Image %d returned twice", img.ID)
+			}
+			seen[img.ID] = true
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	if len(seen) != 7 {
+		t.Errorf("Expected all 7 images, got %d", len(seen))
+	}
+}
+
+func TestListImages_PassesFilters(t *testing.T) {
+	var queries []models.ImageListQuery
+	service := services.NewCatService(fakeListRepo(sampleImages(), &queries), &MockCataasClient{}, discardLogger)
+
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	page, err := service.ListImages(context.Background(), services.ListImagesOptions{
+		ContentType:  "image/gif",
+		CreatedAfter: after,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(page.Images) != 3 || page.NextCursor != "" {
+		t.Errorf("Expected 3 gifs in a single page, got %d (cursor %q)", len(page.Images), page.NextCursor)
+	}
+
+	q := queries[0]
+	if q.Limit != services.DefaultListLimit+1 || q.Sort != models.SortCreatedAt || !q.Descending || !q.CreatedAfter.Equal(after) {
+		t.Errorf("Unexpected query %+v", q)
+	}
+}
+
+func TestListImages_InvalidOptions(t *testing.T) {
+	service := services.NewCatService(fakeListRepo(nil, nil), &MockCataasClient{}, discardLogger)
+
+	first, err := service.ListImages(context.Background(), services.ListImagesOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if first.Images == nil {
+		t.Error("Expected an empty list, not null")
+	}
+
+	tests := []services.ListImagesOptions{
+		{Sort: "image_hash"},
+		{Order: "sideways"},
+		{Limit: services.MaxListLimit + 1},
+		{Cursor: "not-a-cursor"},
+		{CreatedAfter: time.Now(), CreatedBefore: time.Now().Add(-time.Hour)},
+	}
+	for _, opts := range tests {
+		if _, err := service.ListImages(context.Background(), opts); !errors.Is(err, services.ErrInvalidOptions) {
+			t.Errorf("Expected ErrInvalidOptions for %+v, got %v", opts, err)
+		}
+	}
+}
+
+func TestListImages_CursorBoundToSort(t *testing.T) {
+	service := services.NewCatService(fakeListRepo(sampleImages(), nil), &MockCataasClient{}, discardLogger)
+
+	page, err := service.ListImages(context.Background(), services.ListImagesOptions{Limit: 2, Sort: "size"})
+	if err != nil || page.NextCursor == "" {
+		t.Fatalf("Expected a next cursor, got %v", err)
+	}
+
+	_, err = service.ListImages(context.Background(), services.ListImagesOptions{Limit: 2, Sort: "access_count", Cursor: page.NextCursor})
+	if !errors.Is(err, services.ErrInvalidOptions) {
+		t.Errorf("Expected ErrInvalidOptions when reusing a cursor with another sort, got %v", err)
+	}
+}
+
+func TestListImagesHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := services.NewCatService(fakeListRepo(sampleImages(), nil), &MockCataasClient{}, discardLogger)
+	handler := handlers.NewCatHandler(service, discardLogger)
+
+	r := gin.New()
+	r.GET("/api/images", handler.ListImages)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/images?limit=2&created_after=2024-01-01", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var page struct {
+		Images     []map[string]any `json:"images"`
+		NextCursor string           `json:"next_cursor"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(page.Images) != 2 || page.NextCursor == "" {
+		t.Errorf("Expected 2 images and a cursor, got %d (%q)", len(page.Images), page.NextCursor)
+	}
+	if _, ok := page.Images[0]["image_hash"]; !ok {
+		t.Errorf("Expected image metadata, got %v", page.Images[0])
+	}
+
+	for _, query := range []string{"limit=0", "limit=abc", "created_before=yesterday", "sort=bogus"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/images?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q, got %d", query, w.Code)
+		}
+	}
+}
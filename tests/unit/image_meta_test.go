@@ -0,0 +1,126 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/IavilaGw/cat-api/internal/handlers"
+	"github.com/IavilaGw/cat-api/internal/models"
+	"github.com/IavilaGw/cat-api/internal/services"
+)
+
+func TestGetImageMeta_DoesNotCountAccess(t *testing.T) {
+	var accesses, loads int32
+	r := newImageRouter(&accesses, &loads, []byte("cat-bytes"))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/image/1/meta", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+
+	var meta map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &meta); err != nil {
+		t.Fatalf("Expected JSON metadata, got %q", w.Body.String())
+	}
+	if meta["id"] != float64(1) || meta["image_hash"] != "abc123" || meta["content_type"] != "image/jpeg" {
+		t.Errorf("Unexpected metadata %v", meta)
+	}
+	if _, ok := meta["StorageKey"]; ok {
+		t.Error("Storage key should not be exposed")
+	}
+	if accesses != 0 || loads != 0 {
+		t.Errorf("Expected no access recorded and no bytes read, got %d accesses and %d loads", accesses, loads)
+	}
+}
+
+func TestGetImageMeta_InvalidID(t *testing.T) {
+	var accesses, loads int32
+	r := newImageRouter(&accesses, &loads, nil)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/image/abc/meta", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", w.Code)
+	}
+}
+
+func TestGetImageByID_LookupErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockRepo := &MockCatRepository{
+		FindByIDFunc: func(ctx context.Context, id uint) (*models.CatImage, error) {
+			if id == 9 {
+				return nil, services.ErrImageNotFound
+			}
+			return nil, errors.New("connection refused")
+		},
+	}
+	handler := handlers.NewCatHandler(services.NewCatService(mockRepo, &MockCataasClient{}, discardLogger), discardLogger)
+	r := gin.New()
+	r.GET("/api/image/:id", handler.GetImageByID)
+	r.GET("/api/image/:id/meta", handler.GetImageMeta)
+
+	// Solo una imagen inexistente es 404; un fallo de la base no
+	tests := map[string]int{
+		"/api/image/9":      http.StatusNotFound,
+		"/api/image/9/meta": http.StatusNotFound,
+		"/api/image/1":      http.StatusInternalServerError,
+		"/api/image/1/meta": http.StatusInternalServerError,
+	}
+	for path, want := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != want {
+			t.Errorf("%s: expected %d, got %d", path, want, w.Code)
+		}
+	}
+}
+
+func TestGetImageByID_Download(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        string
+	}{
+		{"image/jpeg", `attachment; filename=abc123.jpg`},
+		{"image/gif", `attachment; filename=abc123.gif`},
+		{"image/png; charset=binary", `attachment; filename=abc123.png`},
+		{"application/x-unknown-cat", `attachment; filename=abc123.bin`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			mockRepo := &MockCatRepository{
+				FindByIDFunc: func(ctx context.Context, id uint) (*models.CatImage, error) {
+					return &models.CatImage{ID: id, ImageHash: "abc123", ContentType: tt.contentType}, nil
+				},
+				RecordAccessFunc: func(ctx context.Context, catImage *models.CatImage) error { return nil },
+				OpenDataFunc: func(ctx context.Context, catImage *models.CatImage) (io.ReadSeekCloser, error) {
+					return nopReadSeekCloser{strings.NewReader("cat")}, nil
+				},
+			}
+			handler := handlers.NewCatHandler(services.NewCatService(mockRepo, &MockCataasClient{}, discardLogger), discardLogger)
+			r := gin.New()
+			r.GET("/api/image/:id", handler.GetImageByID)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/image/1?download=1", nil))
+			if got := w.Header().Get("Content-Disposition"); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+
+			w = httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/image/1", nil))
+			if got := w.Header().Get("Content-Disposition"); got != "" {
+				t.Errorf("Expected no Content-Disposition without download, got %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package services_test
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const rangeImage = "0123456789abcdefghij"
+
+func doRangeRequest(t *testing.T, headers map[string]string) (*httptest.ResponseRecorder, int32) {
+	t.Helper()
+	var accesses, loads int32
+	r := newImageRouter(&accesses, &loads, []byte(rangeImage))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/image/1", nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w, accesses
+}
+
+func TestGetImageByID_FullResponseAdvertisesRanges(t *testing.T) {
+	w, accesses := doRangeRequest(t, nil)
+
+	if w.Code != http.StatusOK || w.Body.String() != rangeImage {
+		t.Fatalf("Expected full image, got %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Accept-Ranges") != "bytes" {
+		t.Error("Expected Accept-Ranges: bytes")
+	}
+	if w.Header().Get("Content-Type") != "image/jpeg" {
+		t.Errorf("Expected stored content type, got %q", w.Header().Get("Content-Type"))
+	}
+	if accesses != 1 {
+		t.Errorf("Expected one recorded access, got %d", accesses)
+	}
+}
+
+func TestGetImageByID_SingleRange(t *testing.T) {
+	w, _ := doRangeRequest(t, map[string]string{"Range": "bytes=5-9"})
+
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("Expected 206, got %d", w.Code)
+	}
+	if w.Body.String() != "56789" {
+		t.Errorf("Expected '56789', got %q", w.Body.String())
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes 5-9/20" {
+		t.Errorf("Unexpected Content-Range %q", got)
+	}
+}
+
+func TestGetImageByID_MultiRange(t *testing.T) {
+	w, _ := doRangeRequest(t, map[string]string{"Range": "bytes=0-1,-2"})
+
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("Expected 206, got %d", w.Code)
+	}
+	mediaType, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("Expected multipart/byteranges, got %q", w.Header().Get("Content-Type"))
+	}
+
+	var parts []string
+	reader := multipart.NewReader(w.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read part: %v", err)
+		}
+		data, _ := io.ReadAll(part)
+		parts = append(parts, string(data))
+	}
+	if strings.Join(parts, "|") != "01|ij" {
+		t.Errorf("Expected parts 01|ij, got %v", parts)
+	}
+}
+
+func TestGetImageByID_UnsatisfiableRange(t *testing.T) {
+	w, _ := doRangeRequest(t, map[string]string{"Range": "bytes=100-200"})
+
+	if w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("Expected 416, got %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes */20" {
+		t.Errorf("Unexpected Content-Range %q", got)
+	}
+}
+
+func TestGetImageByID_IfRange(t *testing.T) {
+	// Con el ETag correcto se respeta el rango
+	w, _ := doRangeRequest(t, map[string]string{"Range": "bytes=0-3", "If-Range": `"abc123"`})
+	if w.Code != http.StatusPartialContent || w.Body.String() != "0123" {
+		t.Errorf("Expected 206 '0123', got %d %q", w.Code, w.Body.String())
+	}
+
+	// Con un ETag viejo se devuelve la imagen completa
+	w, _ = doRangeRequest(t, map[string]string{"Range": "bytes=0-3", "If-Range": `"stale"`})
+	if w.Code != http.StatusOK || w.Body.String() != rangeImage {
+		t.Errorf("Expected full 200, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestGetImageByID_PartialRangesNotCounted(t *testing.T) {
+	// Un cliente que sondea repetidamente los primeros bytes no suma accesos
+	for i := 0; i < 3; i++ {
+		if _, accesses := doRangeRequest(t, map[string]string{"Range": "bytes=0-9"}); accesses != 0 {
+			t.Fatalf("Expected a partial range from 0 not to count, got %d", accesses)
+		}
+	}
+
+	tests := []struct {
+		rangeHeader string
+		want        int32
+	}{
+		{"bytes=10-", 0},
+		{"bytes=0-0,5-9", 0},
+		{"bytes=-20", 0},
+		{"bytes=0-", 1},
+		{"bytes=0-19", 1},
+		{"bytes=0-99", 1},
+	}
+	for _, tt := range tests {
+		if _, accesses := doRangeRequest(t, map[string]string{"Range": tt.rangeHeader}); accesses != tt.want {
+			t.Errorf("Range %q: expected %d accesses, got %d", tt.rangeHeader, tt.want, accesses)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/IavilaGw/cat-api/internal/janitor"
+	"github.com/IavilaGw/cat-api/internal/models"
+)
+
+// fakeRetentionStore ordena en memoria igual que las consultas del
+// repositorio real.
+type fakeRetentionStore struct {
+	images  []models.CatImage
+	purged  []uint
+	failing map[uint]bool
+}
+
+func (s *fakeRetentionStore) RetentionTotals(ctx context.Context) (int64, int64, error) {
+	var total int64
+	for _, img := range s.images {
+		total += img.Size
+	}
+	return int64(len(s.images)), total, nil
+}
+
+func (s *fakeRetentionStore) ExpiredImages(ctx context.Context, before time.Time, afterID uint, limit int) ([]models.CatImage, error) {
+	var page []models.CatImage
+	for _, img := range s.sorted(func(a, b models.CatImage) bool { return a.ID < b.ID }) {
+		if img.LastAccessedAt.Before(before) && img.ID > afterID && len(page) < limit {
+			page = append(page, img)
+		}
+	}
+	return page, nil
+}
+
+func (s *fakeRetentionStore) EvictionCandidates(ctx context.Context, policy models.EvictionPolicy, offset, limit int) ([]models.CatImage, error) {
+	ordered := s.sorted(func(a, b models.CatImage) bool {
+		if policy == models.EvictLFU && a.AccessCount != b.AccessCount {
+			return a.AccessCount < b.AccessCount
+		}
+		return a.LastAccessedAt.Before(b.LastAccessedAt)
+	})
+	if offset >= len(ordered) {
+		return nil, nil
+	}
+	return ordered[offset:min(offset+limit, len(ordered))], nil
+}
+
+func (s *fakeRetentionStore) Purge(ctx context.Context, id uint) error {
+	if s.failing[id] {
+		return errors.New("blob store unavailable")
+	}
+	s.purged = append(s.purged, id)
+	return nil
+}
+
+func (s *fakeRetentionStore) sorted(less func(a, b models.CatImage) bool) []models.CatImage {
+	ordered := append([]models.CatImage{}, s.images...)
+	sort.SliceStable(ordered, func(i, j int) bool { return less(ordered[i], ordered[j]) })
+	return ordered
+}
+
+// newRetentionStore crea cuatro imagenes de 100 bytes: la 1 es la menos
+// reciente pero la mas vista, la 4 la mas reciente.
+func newRetentionStore() *fakeRetentionStore {
+	now := time.Now()
+	return &fakeRetentionStore{images: []models.CatImage{
+		{ID: 1, Size: 100, AccessCount: 50, LastAccessedAt: now.Add(-4 * time.Hour)},
+		{ID: 2, Size: 100, AccessCount: 1, LastAccessedAt: now.Add(-3 * time.Hour)},
+		{ID: 3, Size: 100, AccessCount: 2, LastAccessedAt: now.Add(-2 * time.Hour)},
+		{ID: 4, Size: 100, AccessCount: 0, LastAccessedAt: now.Add(-time.Hour)},
+	}}
+}
+
+func evictedIDs(report *janitor.Report) []uint {
+	ids := []uint{}
+	for _, eviction := range report.Evicted {
+		ids = append(ids, eviction.ID)
+	}
+	return ids
+}
+
+func TestJanitor_Policies(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy models.EvictionPolicy
+		want   []uint
+	}{
+		{"lru", models.EvictLRU, []uint{1, 2}},
+		{"lfu", models.EvictLFU, []uint{4, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newRetentionStore()
+			j := janitor.New(store, janitor.Config{MaxImages: 2, Policy: tt.policy}, discardLogger)
+
+			report, err := j.Run(context.Background())
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := evictedIDs(report); !equalIDs(got, tt.want) {
+				t.Errorf("Expected %v evicted, got %v", tt.want, got)
+			}
+			if !equalIDs(store.purged, tt.want) {
+				t.Errorf("Expected %v purged, got %v", tt.want, store.purged)
+			}
+			if report.ImagesAfter != 2 || report.BytesAfter != 200 {
+				t.Errorf("Expected 2 images / 200 bytes left, got %d / %d", report.ImagesAfter, report.BytesAfter)
+			}
+		})
+	}
+}
+
+func TestJanitor_AgeThenBytes(t *testing.T) {
+	store := newRetentionStore()
+	j := janitor.New(store, janitor.Config{
+		MaxAge:   150 * time.Minute,
+		MaxBytes: 150,
+		Policy:   models.EvictLRU,
+	}, discardLogger)
+
+	report, err := j.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// 1 y 2 caducan; despues sobra una imagen por tamaño y se va la 3
+	want := []janitor.Eviction{
+		{ID: 1, Size: 100, Reason: janitor.ReasonAge},
+		{ID: 2, Size: 100, Reason: janitor.ReasonAge},
+		{ID: 3, Size: 100, Reason: janitor.ReasonBytes},
+	}
+	if len(report.Evicted) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, report.Evicted)
+	}
+	for i := range want {
+		if report.Evicted[i] != want[i] {
+			t.Errorf("Eviction %d: expected %+v, got %+v", i, want[i], report.Evicted[i])
+		}
+	}
+	if report.FreedBytes() != 300 {
+		t.Errorf("Expected 300 freed bytes, got %d", report.FreedBytes())
+	}
+}
+
+func TestJanitor_DryRunPurgesNothing(t *testing.T) {
+	store := newRetentionStore()
+	var reported *janitor.Report
+	j := janitor.New(store, janitor.Config{MaxImages: 1, Policy: models.EvictLRU, DryRun: true}, discardLogger).
+		WithReporter(func(r *janitor.Report) { reported = r })
+
+	report, err := j.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(store.purged) != 0 {
+		t.Errorf("Expected nothing purged in dry-run, got %v", store.purged)
+	}
+	if got := evictedIDs(report); !equalIDs(got, []uint{1, 2, 3}) {
+		t.Errorf("Expected [1 2 3] reported, got %v", got)
+	}
+	if reported != report || !report.DryRun {
+		t.Error("Expected the dry-run report to reach the reporter")
+	}
+	if j.LastReport() != report {
+		t.Error("Expected LastReport to return the last run")
+	}
+}
+
+func TestJanitor_PurgeErrorsAreReported(t *testing.T) {
+	store := newRetentionStore()
+	store.failing = map[uint]bool{1: true}
+	j := janitor.New(store, janitor.Config{MaxImages: 2, Policy: models.EvictLRU}, discardLogger)
+
+	report, err := j.Run(context.Background())
+	if err == nil {
+		t.Fatal("Expected an error for the failed purge")
+	}
+	// La 1 falla y no se reintenta con otra imagen en la misma pasada
+	if got := evictedIDs(report); !equalIDs(got, []uint{2}) {
+		t.Errorf("Expected only 2 evicted, got %v", got)
+	}
+	if len(report.Errors) != 1 || report.ImagesAfter != 3 {
+		t.Errorf("Expected 1 error and 3 images left, got %v and %d", report.Errors, report.ImagesAfter)
+	}
+}
+
+func TestJanitor_StartStop(t *testing.T) {
+	store := newRetentionStore()
+	runs := make(chan struct{}, 10)
+	j := janitor.New(store, janitor.Config{Interval: time.Hour, MaxImages: 3, Policy: models.EvictLRU}, discardLogger).
+		WithReporter(func(*janitor.Report) { runs <- struct{}{} })
+
+	j.Start()
+	select {
+	case <-runs:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a run right after Start")
+	}
+	j.Stop()
+	j.Stop()
+
+	if !equalIDs(store.purged, []uint{1}) {
+		t.Errorf("Expected [1] purged, got %v", store.purged)
+	}
+}
+
+// fakeLocker simula el advisory lock compartido entre replicas.
+type fakeLocker struct {
+	held     bool
+	unlocked int
+}
+
+func (l *fakeLocker) TryLock(ctx context.Context) (func(), bool, error) {
+	if l.held {
+		return nil, false, nil
+	}
+	l.held = true
+	return func() { l.held = false; l.unlocked++ }, true, nil
+}
+
+func TestJanitor_SkipsWhenAnotherReplicaHoldsTheLock(t *testing.T) {
+	store := newRetentionStore()
+	locker := &fakeLocker{held: true}
+	reports := 0
+	j := janitor.New(store, janitor.Config{MaxImages: 3, Policy: models.EvictLRU}, discardLogger).
+		WithLocker(locker).
+		WithReporter(func(*janitor.Report) { reports++ })
+
+	report, err := j.Run(context.Background())
+	if err != nil || report != nil {
+		t.Fatalf("Expected the run to be skipped, got %+v (%v)", report, err)
+	}
+	if len(store.purged) != 0 || reports != 0 || j.LastReport() != nil {
+		t.Errorf("Expected a skipped run to purge and report nothing, got purged %v, %d reports", store.purged, reports)
+	}
+
+	// Liberado el lock, la siguiente ejecucion purga y lo suelta al terminar
+	locker.held = false
+	if _, err := j.Run(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !equalIDs(store.purged, []uint{1}) || locker.held || locker.unlocked != 1 {
+		t.Errorf("Expected [1] purged and the lock released, got %v held=%v", store.purged, locker.held)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	if _, err := janitor.ParsePolicy("fifo"); err == nil {
+		t.Error("Expected an error for an unknown policy")
+	}
+	if policy, err := janitor.ParsePolicy("lfu"); err != nil || policy != models.EvictLFU {
+		t.Errorf("Expected lfu, got %q (%v)", policy, err)
+	}
+}
+
+func equalIDs(a, b []uint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
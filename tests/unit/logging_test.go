@@ -0,0 +1,113 @@
+package services_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/IavilaGw/cat-api/internal/config"
+	"github.com/IavilaGw/cat-api/internal/logging"
+	"github.com/IavilaGw/cat-api/internal/middleware"
+)
+
+// newLoggedRouter arma un router con request ID y access log escribiendo JSON en buf.
+func newLoggedRouter(t *testing.T, buf *bytes.Buffer) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	logger, err := logging.New(&config.LogConfig{Level: "info", Format: "json"}, buf)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	r := gin.New()
+	r.Use(middleware.RequestID())
+	r.Use(middleware.AccessLog(logger))
+	r.GET("/api/image/:id", func(c *gin.Context) {
+		logger.InfoContext(c.Request.Context(), "handler")
+		c.Status(http.StatusNotFound)
+	})
+	return r
+}
+
+func decodeLogLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Expected JSON log line, got %q", line)
+		}
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+func TestRequestID_GeneratedAndLogged(t *testing.T) {
+	var buf bytes.Buffer
+	r := newLoggedRouter(t, &buf)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/image/7", nil))
+
+	id := w.Header().Get(middleware.RequestIDHeader)
+	if len(id) != 32 {
+		t.Fatalf("Expected a generated request ID, got %q", id)
+	}
+
+	lines := decodeLogLines(t, &buf)
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 log lines, got %d", len(lines))
+	}
+	for _, entry := range lines {
+		if entry["request_id"] != id {
+			t.Errorf("Expected request_id %q, got %v", id, entry["request_id"])
+		}
+	}
+
+	access := lines[1]
+	if access["level"] != "WARN" || access["route"] != "/api/image/:id" || access["status"] != float64(404) {
+		t.Errorf("Unexpected access log entry: %v", access)
+	}
+}
+
+func TestRequestID_PropagatesIncomingHeader(t *testing.T) {
+	var buf bytes.Buffer
+	r := newLoggedRouter(t, &buf)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/image/7", nil)
+	req.Header.Set(middleware.RequestIDHeader, "abc-123")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if got := w.Header().Get(middleware.RequestIDHeader); got != "abc-123" {
+		t.Errorf("Expected incoming request ID to be reused, got %q", got)
+	}
+}
+
+func TestRequestID_RejectsInvalidHeader(t *testing.T) {
+	var buf bytes.Buffer
+	r := newLoggedRouter(t, &buf)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/image/7", nil)
+	req.Header.Set(middleware.RequestIDHeader, "bad id\twith spaces")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if got := w.Header().Get(middleware.RequestIDHeader); got == "bad id\twith spaces" {
+		t.Error("Expected invalid request ID to be replaced")
+	}
+}
+
+func TestLogging_InvalidConfig(t *testing.T) {
+	if _, err := logging.New(&config.LogConfig{Level: "loud", Format: "json"}, &bytes.Buffer{}); err == nil {
+		t.Error("Expected error for invalid level")
+	}
+	if _, err := logging.New(&config.LogConfig{Level: "info", Format: "xml"}, &bytes.Buffer{}); err == nil {
+		t.Error("Expected error for invalid format")
+	}
+}
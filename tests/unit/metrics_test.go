@@ -0,0 +1,85 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/IavilaGw/cat-api/internal/metrics"
+	"github.com/IavilaGw/cat-api/internal/models"
+	"github.com/IavilaGw/cat-api/internal/services"
+	"github.com/IavilaGw/cat-api/pkg/client"
+)
+
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return w.Body.String()
+}
+
+func TestMetrics_Middleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := metrics.New()
+
+	r := gin.New()
+	r.Use(m.Middleware())
+	r.GET("/api/image/:id", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+
+	for i := 0; i < 2; i++ {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/image/42", nil))
+	}
+
+	body := scrape(t, m)
+	want := `catapi_http_requests_total{method="GET",route="/api/image/:id",status="404"} 2`
+	if !strings.Contains(body, want) {
+		t.Errorf("Expected %q in metrics output", want)
+	}
+	if !strings.Contains(body, "catapi_http_request_duration_seconds_bucket") {
+		t.Error("Expected latency histogram in metrics output")
+	}
+}
+
+func TestMetrics_InstrumentedWrappers(t *testing.T) {
+	m := metrics.New()
+
+	created := true
+	repo := m.InstrumentRepository(&MockCatRepository{
+		SaveFunc: func(ctx context.Context, data []byte, contentType string, variant models.CatVariant) (*models.CatImage, bool, error) {
+			return &models.CatImage{ID: 1}, created, nil
+		},
+	})
+	repo.Save(context.Background(), []byte("a"), "image/jpeg", models.CatVariant{})
+	created = false
+	repo.Save(context.Background(), []byte("a"), "image/jpeg", models.CatVariant{})
+	repo.Save(context.Background(), []byte("a"), "image/jpeg", models.CatVariant{})
+
+	upstream := m.InstrumentClient(&MockCataasClient{
+		GetRandomCatFunc: func(ctx context.Context, opts services.CatOptions) (*services.CatImageResponse, error) {
+			return nil, &client.UpstreamError{StatusCode: 503, Attempts: 3, Err: errors.New("unavailable")}
+		},
+	})
+	upstream.GetRandomCat(context.Background(), services.CatOptions{})
+
+	m.RegisterImageStats(func(ctx context.Context) (*models.CatImageStats, error) {
+		return &models.CatImageStats{TotalImages: 3, TotalSize: 2048}, nil
+	})
+
+	body := scrape(t, m)
+	for _, want := range []string{
+		`catapi_image_dedup_total{result="hit"} 2`,
+		`catapi_image_dedup_total{result="miss"} 1`,
+		`catapi_cataas_errors_total{status="503"} 1`,
+		`catapi_cataas_request_duration_seconds_count{outcome="error"} 1`,
+		`catapi_stored_images 3`,
+		`catapi_stored_image_bytes 2048`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in metrics output", want)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/IavilaGw/cat-api/internal/middleware"
+	"github.com/IavilaGw/cat-api/internal/models"
+	"github.com/IavilaGw/cat-api/internal/ratelimit"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		spec  string
+		burst int
+		want  ratelimit.Limit
+		err   bool
+	}{
+		{"30/m", 0, ratelimit.Limit{Rate: 0.5, Burst: 30}, false},
+		{"10/s", 50, ratelimit.Limit{Rate: 10, Burst: 50}, false},
+		{"3600/h", 0, ratelimit.Limit{Rate: 1, Burst: 3600}, false},
+		{"", 0, ratelimit.Limit{}, false},
+		{"0", 0, ratelimit.Limit{}, false},
+		{"30", 0, ratelimit.Limit{}, true},
+		{"30/d", 0, ratelimit.Limit{}, true},
+		{"x/m", 0, ratelimit.Limit{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ratelimit.ParseLimit(tt.spec, tt.burst)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseLimit(%q, %d) = %+v, %v", tt.spec, tt.burst, got, err)
+		}
+	}
+}
+
+func TestMemoryStore_BurstAndRefill(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	ctx := context.Background()
+	// 20 tokens por segundo: uno cada 50ms
+	limit := ratelimit.Limit{Rate: 20, Burst: 3}
+
+	for i := 2; i >= 0; i-- {
+		d, _ := store.Take(ctx, "a", limit)
+		if !d.Allowed || d.Remaining != i {
+			t.Fatalf("Expected allowed with %d remaining, got %+v", i, d)
+		}
+	}
+
+	d, _ := store.Take(ctx, "a", limit)
+	if d.Allowed || d.RetryAfter <= 0 || d.RetryAfter > 50*time.Millisecond {
+		t.Fatalf("Expected rejection with RetryAfter <= 50ms, got %+v", d)
+	}
+
+	// Otra clave tiene su propio bucket
+	if d, _ := store.Take(ctx, "b", limit); !d.Allowed {
+		t.Error("Expected a separate bucket per key")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if d, _ := store.Take(ctx, "a", limit); !d.Allowed {
+		t.Errorf("Expected a token after refill, got %+v", d)
+	}
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Decision, error) {
+	return ratelimit.Decision{}, errors.New("database down")
+}
+
+// newRateLimitRouter expone /read y /fetch con presupuestos distintos;
+// devuelve tambien una clave con scope admin.
+func newRateLimitRouter(t *testing.T, store ratelimit.Store) (*gin.Engine, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	svc, keys := newTestKeys(t)
+	auth := middleware.NewAPIKeyAuth(svc, discardLogger).WithPublicRead(true)
+	limiter := middleware.NewRateLimiter(store, discardLogger).
+		WithLimit("read", ratelimit.Limit{Rate: 0.1, Burst: 2}).
+		WithLimit("fetch", ratelimit.Limit{Rate: 0.1, Burst: 1})
+
+	r := gin.New()
+	if err := r.SetTrustedProxies([]string{"10.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	r.GET("/read", auth.Require(models.ScopeRead), limiter.Limit("read"), ok)
+	r.GET("/fetch", auth.Require(models.ScopeFetch), limiter.Limit("fetch"), ok)
+	r.GET("/open", limiter.Limit("sin-limite"), ok)
+	return r, keys[models.ScopeAdmin]
+}
+
+func rateLimitRequest(r *gin.Engine, path, remoteAddr string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = remoteAddr
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimiter_PerClientAndGroup(t *testing.T) {
+	r, adminKey := newRateLimitRouter(t, ratelimit.NewMemoryStore())
+
+	for i := 0; i < 2; i++ {
+		w := rateLimitRequest(r, "/read", "192.0.2.1:1234", nil)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("Request %d: expected 204, got %d", i, w.Code)
+		}
+		if w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Remaining") != strconv.Itoa(1-i) {
+			t.Errorf("Unexpected RateLimit headers: %v", w.Header())
+		}
+	}
+
+	w := rateLimitRequest(r, "/read", "192.0.2.1:1234", nil)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d", w.Code)
+	}
+	if retry, _ := strconv.Atoi(w.Header().Get("Retry-After")); retry < 1 || retry > 10 {
+		t.Errorf("Expected Retry-After between 1 and 10, got %q", w.Header().Get("Retry-After"))
+	}
+	if w.Header().Get("RateLimit-Policy") != "2;w=20" || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("Unexpected RateLimit headers on 429: %v", w.Header())
+	}
+
+	// Otra IP, una API key y otro grupo tienen su propio presupuesto
+	if w := rateLimitRequest(r, "/read", "192.0.2.2:1234", nil); w.Code != http.StatusNoContent {
+		t.Errorf("Expected another IP to pass, got %d", w.Code)
+	}
+	if w := rateLimitRequest(r, "/read", "192.0.2.1:1234", map[string]string{"X-API-Key": adminKey}); w.Code != http.StatusNoContent {
+		t.Errorf("Expected an API key to have its own budget, got %d", w.Code)
+	}
+	if w := rateLimitRequest(r, "/fetch", "192.0.2.1:1234", map[string]string{"X-API-Key": adminKey}); w.Code != http.StatusNoContent {
+		t.Errorf("Expected fetch to have its own budget, got %d", w.Code)
+	}
+
+	// Un grupo sin limite configurado no agrega cabeceras
+	if w := rateLimitRequest(r, "/open", "192.0.2.1:1234", nil); w.Header().Get("RateLimit-Limit") != "" {
+		t.Error("Expected no rate limit on an unconfigured group")
+	}
+}
+
+func TestRateLimiter_TrustedProxies(t *testing.T) {
+	r, _ := newRateLimitRouter(t, ratelimit.NewMemoryStore())
+	spoofed := map[string]string{"X-Forwarded-For": "198.51.100.7"}
+
+	// Un cliente directo no puede esquivar el limite inventando X-Forwarded-For
+	for i := 0; i < 2; i++ {
+		spoofed["X-Forwarded-For"] = "198.51.100." + strconv.Itoa(i)
+		rateLimitRequest(r, "/read", "192.0.2.1:1234", spoofed)
+	}
+	if w := rateLimitRequest(r, "/read", "192.0.2.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.9"}); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected spoofed X-Forwarded-For to be ignored, got %d", w.Code)
+	}
+
+	// Detras del proxy de confianza cada cliente real cuenta por separado
+	for i := 0; i < 3; i++ {
+		headers := map[string]string{"X-Forwarded-For": "203.0.113." + strconv.Itoa(i)}
+		if w := rateLimitRequest(r, "/read", "10.0.0.1:4321", headers); w.Code != http.StatusNoContent {
+			t.Errorf("Expected client %d behind the proxy to pass, got %d", i, w.Code)
+		}
+	}
+}
+
+func TestRateLimiter_FailsOpen(t *testing.T) {
+	r, _ := newRateLimitRouter(t, failingRateLimitStore{})
+	for i := 0; i < 5; i++ {
+		if w := rateLimitRequest(r, "/read", "192.0.2.1:1234", nil); w.Code != http.StatusNoContent {
+			t.Fatalf("Expected requests to pass when the store fails, got %d", w.Code)
+		}
+	}
+}
+
+// countingAuthenticator cuenta cuantas claves se buscan en la base.
+type countingAuthenticator struct {
+	middleware.Authenticator
+	lookups int
+}
+
+func (a *countingAuthenticator) Authenticate(ctx context.Context, plaintext string) (*models.APIKey, error) {
+	a.lookups++
+	return a.Authenticator.Authenticate(ctx, plaintext)
+}
+
+func TestRateLimiter_InvalidKeysArePerIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc, keys := newTestKeys(t)
+	counting := &countingAuthenticator{Authenticator: svc}
+	auth := middleware.NewAPIKeyAuth(counting, discardLogger)
+	limiter := middleware.NewRateLimiter(ratelimit.NewMemoryStore(), discardLogger).
+		WithLimit("auth", ratelimit.Limit{Rate: 0.1, Burst: 3}).
+		WithLimit("read", ratelimit.Limit{Rate: 0.1, Burst: 100})
+
+	r := gin.New()
+	r.GET("/read", limiter.LimitKeyLookups("auth"), auth.Require(models.ScopeRead), limiter.Limit("read"),
+		func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	bogus := map[string]string{}
+	for i := 0; i < 3; i++ {
+		bogus["X-API-Key"] = "cat_bogus" + strconv.Itoa(i)
+		if w := rateLimitRequest(r, "/read", "192.0.2.1:1234", bogus); w.Code != http.StatusUnauthorized {
+			t.Fatalf("Request %d: expected 401, got %d", i, w.Code)
+		}
+	}
+
+	// Agotado el presupuesto por IP, ni siquiera se busca la clave
+	for i := 0; i < 5; i++ {
+		if w := rateLimitRequest(r, "/read", "192.0.2.1:1234", bogus); w.Code != http.StatusTooManyRequests {
+			t.Fatalf("Expected 429 for repeated invalid keys, got %d", w.Code)
+		}
+	}
+	if counting.lookups != 3 {
+		t.Errorf("Expected 3 key lookups, got %d", counting.lookups)
+	}
+
+	// Otra IP con una clave valida no se ve afectada
+	valid := map[string]string{"X-API-Key": keys[models.ScopeRead]}
+	if w := rateLimitRequest(r, "/read", "192.0.2.2:1234", valid); w.Code != http.StatusNoContent {
+		t.Errorf("Expected another IP to pass, got %d", w.Code)
+	}
+
+	// Sin clave no hay busqueda, asi que no se cobra
+	if w := rateLimitRequest(r, "/read", "192.0.2.1:1234", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a key, got %d", w.Code)
+	}
+}
@@ -0,0 +1,84 @@
+package services_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IavilaGw/cat-api/internal/models"
+	"github.com/IavilaGw/cat-api/internal/repositories"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqlRecorder guarda el SQL que genera GORM sin ejecutarlo.
+type sqlRecorder struct {
+	logger.Interface
+	statements []string
+}
+
+func (r *sqlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
+// newDryRunRepository devuelve un repositorio que solo genera SQL, sin base.
+func newDryRunRepository(t *testing.T) (*repositories.CatRepository, *sqlRecorder) {
+	t.Helper()
+	recorder := &sqlRecorder{Interface: logger.Discard}
+	db, err := gorm.Open(postgres.Open("host=localhost user=test dbname=test"), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               recorder,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repositories.NewCatRepository(db, nil), recorder
+}
+
+func TestCatRepository_ReadsListColumns(t *testing.T) {
+	repo, recorder := newDryRunRepository(t)
+	ctx := context.Background()
+
+	reads := map[string]func(){
+		"FindByID":           func() { repo.FindByID(ctx, 1) },
+		"FindByHash":         func() { repo.FindByHash(ctx, "abc") },
+		"Purge":              func() { repo.Purge(ctx, 1) },
+		"ExpiredImages":      func() { repo.ExpiredImages(ctx, time.Now(), 0, 10) },
+		"EvictionCandidates": func() { repo.EvictionCandidates(ctx, models.EvictLRU, 0, 10) },
+		"FindRandom":         func() { repo.FindRandom(ctx, models.CatVariant{Tag: "cute"}) },
+		"List":               func() { repo.List(ctx, models.ImageListQuery{Sort: models.SortCreatedAt, Limit: 10}) },
+	}
+	for name, read := range reads {
+		recorder.statements = nil
+		read()
+		if len(recorder.statements) == 0 {
+			t.Errorf("%s: expected a query", name)
+			continue
+		}
+		// La columna image_data antigua no debe volver con la fila
+		sql := recorder.statements[0]
+		if strings.Contains(sql, "*") || strings.Contains(sql, "image_data") {
+			t.Errorf("%s: expected the image columns to be listed, got %s", name, sql)
+		}
+	}
+}
+
+func TestCatRepository_FindRandomMatchesEmptyVariant(t *testing.T) {
+	repo, recorder := newDryRunRepository(t)
+
+	// Una peticion sin opciones tambien filtra por las columnas vacias
+	repo.FindRandom(context.Background(), models.CatVariant{})
+	if len(recorder.statements) == 0 {
+		t.Fatal("Expected a query")
+	}
+	sql := recorder.statements[0]
+	for _, want := range []string{`"variant_says" = ''`, `"variant_tag" = ''`, `"variant_filter" = ''`, `"variant_type" = ''`, `"variant_width" = 0`, `"variant_height" = 0`} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("Expected %s in %s", want, sql)
+		}
+	}
+}
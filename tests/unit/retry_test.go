@@ -0,0 +1,125 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IavilaGw/cat-api/pkg/client"
+)
+
+var fastRetry = client.RetryPolicy{
+	MaxAttempts: 3,
+	BaseBackoff: time.Millisecond,
+	MaxBackoff:  50 * time.Millisecond,
+	Jitter:      0.5,
+}
+
+// flakyServer responde con los codigos indicados y luego con una imagen.
+func flakyServer(statuses []int, headers http.Header) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if int(n) <= len(statuses) {
+			for k, v := range headers {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write([]byte("cat"))
+	}))
+	return server, &calls
+}
+
+func TestCataasClient_RetriesTransientErrors(t *testing.T) {
+	server, calls := flakyServer([]int{http.StatusBadGateway, http.StatusTooManyRequests}, nil)
+	defer server.Close()
+
+	c := client.NewCataasClient(server.URL, 5).WithRetryPolicy(fastRetry)
+	resp, err := c.GetRandomCat(context.Background(), client.CatOptions{})
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.Attempts != 3 || atomic.LoadInt32(calls) != 3 {
+		t.Errorf("Expected 3 attempts, got %d (%d calls)", resp.Attempts, atomic.LoadInt32(calls))
+	}
+}
+
+func TestCataasClient_DoesNotRetryClientErrors(t *testing.T) {
+	server, calls := flakyServer([]int{http.StatusNotFound}, nil)
+	defer server.Close()
+
+	c := client.NewCataasClient(server.URL, 5).WithRetryPolicy(fastRetry)
+	_, err := c.GetRandomCat(context.Background(), client.CatOptions{Tag: "missing"})
+
+	var upstreamErr *client.UpstreamError
+	if !errors.As(err, &upstreamErr) {
+		t.Fatalf("Expected UpstreamError, got %v", err)
+	}
+	if upstreamErr.Attempts != 1 || upstreamErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a single 404 attempt, got %+v", upstreamErr)
+	}
+	if atomic.LoadInt32(calls) != 1 {
+		t.Errorf("Expected 1 call, got %d", atomic.LoadInt32(calls))
+	}
+}
+
+func TestCataasClient_GivesUpAfterMaxAttempts(t *testing.T) {
+	server, calls := flakyServer([]int{500, 500, 500, 500}, nil)
+	defer server.Close()
+
+	c := client.NewCataasClient(server.URL, 5).WithRetryPolicy(fastRetry)
+	_, err := c.GetRandomCat(context.Background(), client.CatOptions{})
+
+	var upstreamErr *client.UpstreamError
+	if !errors.As(err, &upstreamErr) || upstreamErr.Attempts != 3 {
+		t.Fatalf("Expected UpstreamError after 3 attempts, got %v", err)
+	}
+	if atomic.LoadInt32(calls) != 3 {
+		t.Errorf("Expected 3 calls, got %d", atomic.LoadInt32(calls))
+	}
+}
+
+func TestCataasClient_HonorsRetryAfter(t *testing.T) {
+	server, _ := flakyServer([]int{http.StatusServiceUnavailable}, http.Header{"Retry-After": {"1"}})
+	defer server.Close()
+
+	policy := fastRetry
+	policy.MaxBackoff = 2 * time.Second
+
+	c := client.NewCataasClient(server.URL, 5).WithRetryPolicy(policy)
+	start := time.Now()
+	resp, err := c.GetRandomCat(context.Background(), client.CatOptions{})
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Expected to wait at least Retry-After, waited %v", elapsed)
+	}
+	if resp.Attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", resp.Attempts)
+	}
+}
+
+func TestCataasClient_RetryAfterBeyondBudget(t *testing.T) {
+	server, calls := flakyServer([]int{http.StatusTooManyRequests}, http.Header{"Retry-After": {"120"}})
+	defer server.Close()
+
+	c := client.NewCataasClient(server.URL, 5).WithRetryPolicy(fastRetry)
+	_, err := c.GetRandomCat(context.Background(), client.CatOptions{})
+
+	if err == nil {
+		t.Fatal("Expected error when Retry-After exceeds the max backoff")
+	}
+	if atomic.LoadInt32(calls) != 1 {
+		t.Errorf("Expected 1 call, got %d", atomic.LoadInt32(calls))
+	}
+}
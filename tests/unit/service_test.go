@@ -1,65 +1,143 @@
 package services_test
 
 import (
+	"context"
 	"errors"
+	"io"
+	"log/slog"
 	"testing"
 
 	"github.com/IavilaGw/cat-api/internal/models"
 	"github.com/IavilaGw/cat-api/internal/services"
 )
 
+// discardLogger descarta los logs para no ensuciar la salida de los tests
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
 // Mock del repositorio
 type MockCatRepository struct {
-	SaveFunc        func([]byte, string) (*models.CatImage, error)
-	CountUniqueFunc func() (int64, error)
-	GetStatsFunc    func() (*models.CatImageStats, error)
-	FindByIDFunc    func(uint) (*models.CatImage, error)
+	SaveFunc         func(context.Context, []byte, string, models.CatVariant) (*models.CatImage, bool, error)
+	CountUniqueFunc  func(context.Context) (int64, error)
+	GetStatsFunc     func(context.Context) (*models.CatImageStats, error)
+	FindByIDFunc     func(context.Context, uint) (*models.CatImage, error)
+	FindByHashFunc   func(context.Context, string) (*models.CatImage, error)
+	FindRandomFunc   func(context.Context, models.CatVariant) (*models.CatImage, error)
+	ListFunc         func(context.Context, models.ImageListQuery) ([]models.CatImage, error)
+	RecordAccessFunc func(context.Context, *models.CatImage) error
+	SoftDeleteFunc   func(context.Context, uint) error
+	RestoreFunc      func(context.Context, uint) (*models.CatImage, error)
+	PurgeFunc        func(context.Context, uint) error
+	LoadDataFunc     func(context.Context, *models.CatImage) ([]byte, error)
+	OpenDataFunc     func(context.Context, *models.CatImage) (io.ReadSeekCloser, error)
 }
 
-func (m *MockCatRepository) Save(data []byte, contentType string) (*models.CatImage, error) {
+func (m *MockCatRepository) Save(ctx context.Context, data []byte, contentType string, variant models.CatVariant) (*models.CatImage, bool, error) {
 	if m.SaveFunc != nil {
-		return m.SaveFunc(data, contentType)
+		return m.SaveFunc(ctx, data, contentType, variant)
 	}
-	return nil, errors.New("not implemented")
+	return nil, false, errors.New("not implemented")
 }
 
-func (m *MockCatRepository) CountUnique() (int64, error) {
+func (m *MockCatRepository) CountUnique(ctx context.Context) (int64, error) {
 	if m.CountUniqueFunc != nil {
-		return m.CountUniqueFunc()
+		return m.CountUniqueFunc(ctx)
 	}
 	return 0, errors.New("not implemented")
 }
 
-func (m *MockCatRepository) GetStats() (*models.CatImageStats, error) {
+func (m *MockCatRepository) GetStats(ctx context.Context) (*models.CatImageStats, error) {
 	if m.GetStatsFunc != nil {
-		return m.GetStatsFunc()
+		return m.GetStatsFunc(ctx)
 	}
 	return nil, errors.New("not implemented")
 }
 
-func (m *MockCatRepository) FindByID(id uint) (*models.CatImage, error) {
+func (m *MockCatRepository) FindByID(ctx context.Context, id uint) (*models.CatImage, error) {
 	if m.FindByIDFunc != nil {
-		return m.FindByIDFunc(id)
+		return m.FindByIDFunc(ctx, id)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockCatRepository) FindByHash(ctx context.Context, hash string) (*models.CatImage, error) {
+	if m.FindByHashFunc != nil {
+		return m.FindByHashFunc(ctx, hash)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockCatRepository) FindRandom(ctx context.Context, variant models.CatVariant) (*models.CatImage, error) {
+	if m.FindRandomFunc != nil {
+		return m.FindRandomFunc(ctx, variant)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockCatRepository) List(ctx context.Context, q models.ImageListQuery) ([]models.CatImage, error) {
+	if m.ListFunc != nil {
+		return m.ListFunc(ctx, q)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockCatRepository) RecordAccess(ctx context.Context, catImage *models.CatImage) error {
+	if m.RecordAccessFunc != nil {
+		return m.RecordAccessFunc(ctx, catImage)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockCatRepository) SoftDelete(ctx context.Context, id uint) error {
+	if m.SoftDeleteFunc != nil {
+		return m.SoftDeleteFunc(ctx, id)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockCatRepository) Restore(ctx context.Context, id uint) (*models.CatImage, error) {
+	if m.RestoreFunc != nil {
+		return m.RestoreFunc(ctx, id)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockCatRepository) Purge(ctx context.Context, id uint) error {
+	if m.PurgeFunc != nil {
+		return m.PurgeFunc(ctx, id)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockCatRepository) OpenData(ctx context.Context, catImage *models.CatImage) (io.ReadSeekCloser, error) {
+	if m.OpenDataFunc != nil {
+		return m.OpenDataFunc(ctx, catImage)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockCatRepository) LoadData(ctx context.Context, catImage *models.CatImage) ([]byte, error) {
+	if m.LoadDataFunc != nil {
+		return m.LoadDataFunc(ctx, catImage)
 	}
 	return nil, errors.New("not implemented")
 }
 
 // Mock del cliente
 type MockCataasClient struct {
-	GetRandomCatFunc func() (*services.CatImageResponse, error)
-	HealthCheckFunc  func() error
+	GetRandomCatFunc func(context.Context, services.CatOptions) (*services.CatImageResponse, error)
+	HealthCheckFunc  func(context.Context) error
 }
 
-func (m *MockCataasClient) GetRandomCat() (*services.CatImageResponse, error) {
+func (m *MockCataasClient) GetRandomCat(ctx context.Context, opts services.CatOptions) (*services.CatImageResponse, error) {
 	if m.GetRandomCatFunc != nil {
-		return m.GetRandomCatFunc()
+		return m.GetRandomCatFunc(ctx, opts)
 	}
 	return nil, errors.New("not implemented")
 }
 
-func (m *MockCataasClient) HealthCheck() error {
+func (m *MockCataasClient) HealthCheck(ctx context.Context) error {
 	if m.HealthCheckFunc != nil {
-		return m.HealthCheckFunc()
+		return m.HealthCheckFunc(ctx)
 	}
 	return errors.New("not implemented")
 }
@@ -68,19 +146,18 @@ func (m *MockCataasClient) HealthCheck() error {
 
 func TestFetchAndSaveRandomCat_Success(t *testing.T) {
 	mockRepo := &MockCatRepository{
-		SaveFunc: func(data []byte, contentType string) (*models.CatImage, error) {
+		SaveFunc: func(ctx context.Context, data []byte, contentType string, variant models.CatVariant) (*models.CatImage, bool, error) {
 			return &models.CatImage{
 				ID:          1,
-				ImageData:   data,
 				ImageHash:   "test-hash",
 				ContentType: contentType,
 				Size:        int64(len(data)),
-			}, nil
+			}, true, nil
 		},
 	}
 
 	mockClient := &MockCataasClient{
-		GetRandomCatFunc: func() (*services.CatImageResponse, error) {
+		GetRandomCatFunc: func(ctx context.Context, opts services.CatOptions) (*services.CatImageResponse, error) {
 			return &services.CatImageResponse{
 				Data:        []byte("fake-image-data"),
 				ContentType: "image/jpeg",
@@ -89,38 +166,38 @@ func TestFetchAndSaveRandomCat_Success(t *testing.T) {
 		},
 	}
 
-	service := services.NewCatService(mockRepo, mockClient)
+	service := services.NewCatService(mockRepo, mockClient, discardLogger)
 
-	catImage, imageData, err := service.FetchAndSaveRandomCat()
+	result, err := service.FetchAndSaveRandomCat(context.Background(), services.CatOptions{})
 
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
 
-	if catImage == nil {
+	if result == nil || result.Image == nil {
 		t.Fatal("Expected cat image, got nil")
 	}
 
-	if catImage.ID != 1 {
-		t.Errorf("Expected ID 1, got %d", catImage.ID)
+	if result.Image.ID != 1 {
+		t.Errorf("Expected ID 1, got %d", result.Image.ID)
 	}
 
-	if string(imageData) != "fake-image-data" {
-		t.Errorf("Expected 'fake-image-data', got %s", string(imageData))
+	if string(result.Data) != "fake-image-data" {
+		t.Errorf("Expected 'fake-image-data', got %s", string(result.Data))
 	}
 }
 
 func TestGetUniqueImageCount(t *testing.T) {
 	mockRepo := &MockCatRepository{
-		CountUniqueFunc: func() (int64, error) {
+		CountUniqueFunc: func(ctx context.Context) (int64, error) {
 			return 42, nil
 		},
 	}
 
 	mockClient := &MockCataasClient{}
-	service := services.NewCatService(mockRepo, mockClient)
+	service := services.NewCatService(mockRepo, mockClient, discardLogger)
 
-	count, err := service.GetUniqueImageCount()
+	count, err := service.GetUniqueImageCount(context.Background())
 
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
@@ -133,15 +210,15 @@ func TestGetUniqueImageCount(t *testing.T) {
 
 func TestGetUniqueImageCountError(t *testing.T) {
 	mockRepo := &MockCatRepository{
-		CountUniqueFunc: func() (int64, error) {
+		CountUniqueFunc: func(ctx context.Context) (int64, error) {
 			return 0, errors.New("database error")
 		},
 	}
 
 	mockClient := &MockCataasClient{}
-	service := services.NewCatService(mockRepo, mockClient)
+	service := services.NewCatService(mockRepo, mockClient, discardLogger)
 
-	count, err := service.GetUniqueImageCount()
+	count, err := service.GetUniqueImageCount(context.Background())
 
 	if err == nil {
 		t.Error("Expected error, got nil")
@@ -161,15 +238,15 @@ func TestGetStats(t *testing.T) {
 	}
 
 	mockRepo := &MockCatRepository{
-		GetStatsFunc: func() (*models.CatImageStats, error) {
+		GetStatsFunc: func(ctx context.Context) (*models.CatImageStats, error) {
 			return expectedStats, nil
 		},
 	}
 
 	mockClient := &MockCataasClient{}
-	service := services.NewCatService(mockRepo, mockClient)
+	service := services.NewCatService(mockRepo, mockClient, discardLogger)
 
-	stats, err := service.GetStats()
+	stats, err := service.GetStats(context.Background())
 
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
@@ -192,3 +269,152 @@ func TestGetStats(t *testing.T) {
 	}
 }
 
+func TestFetchAndSaveRandomCat_WithOptions(t *testing.T) {
+	opts := services.CatOptions{Tag: "cute", Says: "hola", Filter: "mono", Width: 200}
+
+	var savedVariant models.CatVariant
+	mockRepo := &MockCatRepository{
+		SaveFunc: func(ctx context.Context, data []byte, contentType string, variant models.CatVariant) (*models.CatImage, bool, error) {
+			savedVariant = variant
+			return &models.CatImage{ID: 1, ContentType: contentType, Variant: variant}, true, nil
+		},
+	}
+
+	var receivedOpts services.CatOptions
+	mockClient := &MockCataasClient{
+		GetRandomCatFunc: func(ctx context.Context, o services.CatOptions) (*services.CatImageResponse, error) {
+			receivedOpts = o
+			return &services.CatImageResponse{Data: []byte("img"), ContentType: "image/png", Size: 3}, nil
+		},
+	}
+
+	service := services.NewCatService(mockRepo, mockClient, discardLogger)
+
+	if _, err := service.FetchAndSaveRandomCat(context.Background(), opts); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if receivedOpts != opts {
+		t.Errorf("Expected client to receive %+v, got %+v", opts, receivedOpts)
+	}
+
+	if savedVariant != opts.Variant() {
+		t.Errorf("Expected variant %+v, got %+v", opts.Variant(), savedVariant)
+	}
+}
+
+func TestFetchAndSaveRandomCat_InvalidOptions(t *testing.T) {
+	invalid := []services.CatOptions{
+		{Tag: "bad tag"},
+		{Filter: "sparkle"},
+		{Type: "huge"},
+		{Width: 10000},
+		{Height: -1},
+	}
+
+	for _, opts := range invalid {
+		mockClient := &MockCataasClient{
+			GetRandomCatFunc: func(context.Context, services.CatOptions) (*services.CatImageResponse, error) {
+				t.Fatal("client should not be called with invalid options")
+				return nil, nil
+			},
+		}
+		service := services.NewCatService(&MockCatRepository{}, mockClient, discardLogger)
+
+		_, err := service.FetchAndSaveRandomCat(context.Background(), opts)
+		if !errors.Is(err, services.ErrInvalidOptions) {
+			t.Errorf("Expected ErrInvalidOptions for %+v, got %v", opts, err)
+		}
+	}
+}
+
+func TestFetchAndSaveRandomCat_FallbackToCache(t *testing.T) {
+	mockRepo := &MockCatRepository{
+		FindRandomFunc: func(ctx context.Context, variant models.CatVariant) (*models.CatImage, error) {
+			if variant.Tag != "cute" {
+				t.Errorf("Expected fallback to keep the requested tag, got %q", variant.Tag)
+			}
+			return &models.CatImage{ID: 7, ContentType: "image/jpeg"}, nil
+		},
+		LoadDataFunc: func(ctx context.Context, catImage *models.CatImage) ([]byte, error) {
+			return []byte("cached-cat"), nil
+		},
+		GetStatsFunc: func(ctx context.Context) (*models.CatImageStats, error) {
+			return &models.CatImageStats{}, nil
+		},
+	}
+
+	mockClient := &MockCataasClient{
+		GetRandomCatFunc: func(ctx context.Context, opts services.CatOptions) (*services.CatImageResponse, error) {
+			return nil, errors.New("upstream down")
+		},
+	}
+
+	service := services.NewCatService(mockRepo, mockClient, discardLogger)
+
+	result, err := service.FetchAndSaveRandomCat(context.Background(), services.CatOptions{Tag: "cute"})
+	if err != nil {
+		t.Fatalf("Expected fallback, got error %v", err)
+	}
+	if result.Source != services.SourceCache || result.Image.ID != 7 || string(result.Data) != "cached-cat" {
+		t.Errorf("Expected cached image 7, got %+v", result)
+	}
+
+	stats, err := service.GetStats(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stats.FallbackServed != 1 {
+		t.Errorf("Expected 1 fallback served, got %d", stats.FallbackServed)
+	}
+}
+
+func TestFetchAndSaveRandomCat_FallbackEmptyCache(t *testing.T) {
+	upstreamErr := errors.New("upstream down")
+	mockRepo := &MockCatRepository{
+		FindRandomFunc: func(ctx context.Context, variant models.CatVariant) (*models.CatImage, error) {
+			return nil, errors.New("image not found")
+		},
+	}
+	mockClient := &MockCataasClient{
+		GetRandomCatFunc: func(ctx context.Context, opts services.CatOptions) (*services.CatImageResponse, error) {
+			return nil, upstreamErr
+		},
+	}
+
+	service := services.NewCatService(mockRepo, mockClient, discardLogger)
+
+	_, err := service.FetchAndSaveRandomCat(context.Background(), services.CatOptions{})
+	if !errors.Is(err, upstreamErr) {
+		t.Errorf("Expected the upstream error, got %v", err)
+	}
+}
+
+func TestFetchAndSaveRandomCat_DeletedImageStaysHidden(t *testing.T) {
+	mockRepo := &MockCatRepository{
+		SaveFunc: func(ctx context.Context, data []byte, contentType string, variant models.CatVariant) (*models.CatImage, bool, error) {
+			return &models.CatImage{ID: 3}, false, services.ErrImageDeleted
+		},
+		FindRandomFunc: func(ctx context.Context, variant models.CatVariant) (*models.CatImage, error) {
+			return &models.CatImage{ID: 8, ContentType: "image/jpeg"}, nil
+		},
+		LoadDataFunc: func(ctx context.Context, catImage *models.CatImage) ([]byte, error) {
+			return []byte("other-cat"), nil
+		},
+	}
+	mockClient := &MockCataasClient{
+		GetRandomCatFunc: func(ctx context.Context, opts services.CatOptions) (*services.CatImageResponse, error) {
+			return &services.CatImageResponse{Data: []byte("deleted-cat"), ContentType: "image/jpeg", Size: 11}, nil
+		},
+	}
+
+	service := services.NewCatService(mockRepo, mockClient, discardLogger)
+
+	result, err := service.FetchAndSaveRandomCat(context.Background(), services.CatOptions{})
+	if err != nil {
+		t.Fatalf("Expected a stored image instead, got %v", err)
+	}
+	if result.Image.ID != 8 || string(result.Data) != "other-cat" {
+		t.Errorf("Expected the deleted image to be replaced by a cached one, got %+v", result)
+	}
+}
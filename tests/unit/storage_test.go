@@ -0,0 +1,167 @@
+package services_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IavilaGw/cat-api/internal/storage"
+)
+
+// fakeS3 es un reemplazo minimo de MinIO: guarda objetos en memoria
+// usando rutas estilo path (/bucket/key).
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newFakeS3() *httptest.Server {
+	f := &fakeS3{objects: make(map[string][]byte)}
+	return httptest.NewServer(f)
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+			data = decodeAWSChunked(data)
+		}
+		f.objects[key] = data
+		w.Header().Set("ETag", `"etag"`)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`)
+			}
+			return
+		}
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		// ServeContent atiende los GET con Range que hace el cliente al hacer Seek
+		http.ServeContent(w, r, "", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC), bytes.NewReader(data))
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// decodeAWSChunked quita el framing "size;chunk-signature=...\r\n" que el
+// cliente usa al firmar en streaming sobre HTTP plano.
+func decodeAWSChunked(body []byte) []byte {
+	var out []byte
+	for len(body) > 0 {
+		line, rest, ok := bytes.Cut(body, []byte("\r\n"))
+		if !ok {
+			break
+		}
+		sizeHex, _, _ := strings.Cut(string(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil || size == 0 || int64(len(rest)) < size {
+			break
+		}
+		out = append(out, rest[:size]...)
+		body = bytes.TrimPrefix(rest[size:], []byte("\r\n"))
+	}
+	return out
+}
+
+func exerciseBlobStore(t *testing.T, store storage.BlobStore) {
+	ctx := context.Background()
+	key := storage.KeyForHash("abcdef")
+
+	if _, err := store.Get(ctx, key); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if _, err := store.Open(ctx, key); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound on open, got %v", err)
+	}
+
+	if err := store.Put(ctx, key, []byte("cat-bytes"), "image/jpeg"); err != nil {
+		t.Fatalf("Expected no error on put, got %v", err)
+	}
+
+	exists, err := store.Exists(ctx, key)
+	if err != nil || !exists {
+		t.Errorf("Expected blob to exist, got %v (%v)", exists, err)
+	}
+
+	data, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Expected no error on get, got %v", err)
+	}
+	if string(data) != "cat-bytes" {
+		t.Errorf("Expected 'cat-bytes', got %s", string(data))
+	}
+
+	reader, err := store.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("Expected no error on open, got %v", err)
+	}
+	if _, err := reader.Seek(4, io.SeekStart); err != nil {
+		t.Fatalf("Expected no error on seek, got %v", err)
+	}
+	rest, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil || string(rest) != "bytes" {
+		t.Errorf("Expected 'bytes' after seeking, got %q (%v)", rest, err)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Expected no error on delete, got %v", err)
+	}
+
+	exists, err = store.Exists(ctx, key)
+	if err != nil || exists {
+		t.Errorf("Expected blob to be gone, got %v (%v)", exists, err)
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	exerciseBlobStore(t, store)
+
+	if err := store.Put(context.Background(), "../escape", []byte("x"), "image/jpeg"); err == nil {
+		t.Error("Expected error for key outside the store root")
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	server := newFakeS3()
+	defer server.Close()
+
+	store, err := storage.NewS3Store(storage.S3Options{
+		Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+		Region:    "us-east-1",
+		Bucket:    "cats",
+		AccessKey: "minio",
+		SecretKey: "minio123",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	exerciseBlobStore(t, store)
+}
@@ -0,0 +1,317 @@
+package services_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/IavilaGw/cat-api/internal/middleware"
+	"github.com/IavilaGw/cat-api/internal/tlsconfig"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// issueCert firma un certificado con parent, o lo autofirma si parent es nil.
+func issueCert(t *testing.T, serial int64, isCA bool, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "cat-api-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:         isCA,
+
+		BasicConstraintsValid: true,
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{cert: cert, key: key}
+}
+
+// writeCert escribe el certificado y la clave en PEM y adelanta el mtime
+// para que el cambio se note aunque ocurra en el mismo instante.
+func writeCert(t *testing.T, c *testCert, certFile, keyFile string, mtime time.Time) {
+	t.Helper()
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	os.Chtimes(certFile, mtime, mtime)
+	os.Chtimes(keyFile, mtime, mtime)
+}
+
+func servedSerial(t *testing.T, r *tlsconfig.Reloader) int64 {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.SerialNumber.Int64()
+}
+
+func TestReloader_PicksUpNewCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	start := time.Now()
+	writeCert(t, issueCert(t, 1, false, nil), certFile, keyFile, start)
+
+	reloader, err := tlsconfig.NewReloader(certFile, keyFile, discardLogger)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if servedSerial(t, reloader) != 1 {
+		t.Fatal("Expected the initial certificate")
+	}
+
+	if reloaded, err := reloader.Reload(); reloaded || err != nil {
+		t.Errorf("Expected no reload for unchanged files, got %v (%v)", reloaded, err)
+	}
+
+	writeCert(t, issueCert(t, 2, false, nil), certFile, keyFile, start.Add(time.Second))
+	if reloaded, err := reloader.Reload(); !reloaded || err != nil {
+		t.Fatalf("Expected a reload, got %v (%v)", reloaded, err)
+	}
+	if servedSerial(t, reloader) != 2 {
+		t.Error("Expected the renewed certificate to be served")
+	}
+
+	// Un certificado roto no reemplaza al que esta en uso
+	os.WriteFile(certFile, []byte("not a certificate"), 0o600)
+	if _, err := reloader.Reload(); err == nil {
+		t.Error("Expected an error for a broken certificate")
+	}
+	if servedSerial(t, reloader) != 2 {
+		t.Error("Expected the previous certificate to stay in use")
+	}
+}
+
+func TestReloader_StartStop(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	start := time.Now()
+	writeCert(t, issueCert(t, 1, false, nil), certFile, keyFile, start)
+
+	reloader, err := tlsconfig.NewReloader(certFile, keyFile, discardLogger)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	reloader.Start(10 * time.Millisecond)
+	defer reloader.Stop()
+
+	writeCert(t, issueCert(t, 7, false, nil), certFile, keyFile, start.Add(time.Second))
+	deadline := time.Now().Add(2 * time.Second)
+	for servedSerial(t, reloader) != 7 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the watcher to reload the certificate")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTLSConfig_InvalidOptions(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCert(t, issueCert(t, 1, false, nil), certFile, keyFile, time.Now())
+	reloader, _ := tlsconfig.NewReloader(certFile, keyFile, discardLogger)
+
+	tests := []tlsconfig.Options{
+		{MinVersion: "1.0"},
+		{ClientAuth: "require"},
+		{ClientCAFile: keyFile},
+		{ClientCAFile: certFile, ClientAuth: "sometimes"},
+	}
+	for _, opts := range tests {
+		if _, err := tlsconfig.New(opts, reloader); err == nil {
+			t.Errorf("Expected an error for %+v", opts)
+		}
+	}
+
+	if _, err := tlsconfig.NewReloader(filepath.Join(dir, "missing.crt"), keyFile, discardLogger); err == nil {
+		t.Error("Expected an error for a missing certificate")
+	}
+}
+
+// serveTLS levanta un servidor HTTPS con la configuracion dada.
+func serveTLS(t *testing.T, cfg *tls.Config) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}),
+		// Los handshakes rechazados son esperados
+		ErrorLog: log.New(io.Discard, "", 0),
+	}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return "https://" + ln.Addr().String()
+}
+
+func tlsClient(ca *testCert, clientCert *testCert) *http.Client {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	cfg := &tls.Config{RootCAs: roots}
+	if clientCert != nil {
+		cfg.Certificates = []tls.Certificate{{Certificate: [][]byte{clientCert.cert.Raw}, PrivateKey: clientCert.key}}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}, Timeout: 5 * time.Second}
+}
+
+func TestTLSConfig_ClientAuthValues(t *testing.T) {
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: issueCert(t, 1, true, nil).cert.Raw}), 0o600)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCert(t, issueCert(t, 2, false, nil), certFile, keyFile, time.Now())
+	reloader, _ := tlsconfig.NewReloader(certFile, keyFile, discardLogger)
+
+	// Los mismos valores que acepta TLS_CLIENT_AUTH en la validacion de config
+	tests := []struct {
+		clientAuth string
+		caFile     string
+		want       tls.ClientAuthType
+		wantErr    bool
+	}{
+		{"", "", tls.NoClientCert, false},
+		{"", caFile, tls.RequireAndVerifyClientCert, false},
+		{"require", caFile, tls.RequireAndVerifyClientCert, false},
+		{"optional", caFile, tls.VerifyClientCertIfGiven, false},
+		{"require", "", 0, true},
+		{"optional", "", 0, true},
+		{"none", "", 0, true},
+		{"none", caFile, 0, true},
+		{"sometimes", caFile, 0, true},
+	}
+	for _, tt := range tests {
+		cfg, err := tlsconfig.New(tlsconfig.Options{ClientCAFile: tt.caFile, ClientAuth: tt.clientAuth}, reloader)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ClientAuth %q with CA %q: expected error=%v, got %v", tt.clientAuth, tt.caFile, tt.wantErr, err)
+			continue
+		}
+		if err == nil && cfg.ClientAuth != tt.want {
+			t.Errorf("ClientAuth %q with CA %q: expected %v, got %v", tt.clientAuth, tt.caFile, tt.want, cfg.ClientAuth)
+		}
+	}
+}
+
+func TestTLSConfig_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := issueCert(t, 1, true, nil)
+	caFile := filepath.Join(dir, "ca.crt")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0o600)
+
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCert(t, issueCert(t, 2, false, ca), certFile, keyFile, time.Now())
+	reloader, err := tlsconfig.NewReloader(certFile, keyFile, discardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := issueCert(t, 3, false, ca)
+	stranger := issueCert(t, 4, false, issueCert(t, 5, true, nil))
+
+	tests := []struct {
+		name       string
+		clientAuth string
+		cert       *testCert
+		wantOK     bool
+	}{
+		{"require con certificado", "require", client, true},
+		{"require sin certificado", "require", nil, false},
+		{"require con otra CA", "require", stranger, false},
+		{"optional sin certificado", "optional", nil, true},
+		{"optional con otra CA", "optional", stranger, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := tlsconfig.New(tlsconfig.Options{MinVersion: "1.3", ClientCAFile: caFile, ClientAuth: tt.clientAuth}, reloader)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			url := serveTLS(t, cfg)
+
+			resp, err := tlsClient(ca, tt.cert).Get(url)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if ok := err == nil && resp.StatusCode == http.StatusNoContent; ok != tt.wantOK {
+				t.Errorf("Expected ok=%v, got %v", tt.wantOK, err)
+			}
+		})
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.SecurityHeaders(24 * time.Hour))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := map[string]string{
+		"Strict-Transport-Security": "max-age=86400; includeSubDomains",
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "DENY",
+	}
+	for header, value := range want {
+		if got := w.Header().Get(header); got != value {
+			t.Errorf("Expected %s %q, got %q", header, value, got)
+		}
+	}
+
+	// Sin max-age no se envia HSTS
+	r = gin.New()
+	r.Use(middleware.SecurityHeaders(0))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Header().Get("Strict-Transport-Security") != "" || w.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("Unexpected headers without HSTS: %v", w.Header())
+	}
+}
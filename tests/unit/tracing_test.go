@@ -0,0 +1,98 @@
+package services_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/IavilaGw/cat-api/internal/models"
+	"github.com/IavilaGw/cat-api/internal/services"
+	"github.com/IavilaGw/cat-api/pkg/client"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func installRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	return recorder
+}
+
+func TestTracing_FetchAndSaveSpans(t *testing.T) {
+	recorder := installRecorder(t)
+
+	mockRepo := &MockCatRepository{
+		SaveFunc: func(ctx context.Context, data []byte, contentType string, variant models.CatVariant) (*models.CatImage, bool, error) {
+			return &models.CatImage{ID: 1}, false, nil
+		},
+	}
+	mockClient := &MockCataasClient{
+		GetRandomCatFunc: func(ctx context.Context, opts services.CatOptions) (*services.CatImageResponse, error) {
+			return &services.CatImageResponse{Data: []byte("cat"), ContentType: "image/jpeg", Attempts: 1}, nil
+		},
+	}
+
+	service := services.NewCatService(mockRepo, mockClient, discardLogger)
+	if _, err := service.FetchAndSaveRandomCat(context.Background(), services.CatOptions{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	root, ok := spans["CatService.FetchAndSaveRandomCat"]
+	if !ok {
+		t.Fatal("Expected a CatService.FetchAndSaveRandomCat span")
+	}
+	for _, name := range []string{"CatService.fetchUpstream", "CatService.saveImage"} {
+		child, ok := spans[name]
+		if !ok {
+			t.Errorf("Expected a %s span", name)
+			continue
+		}
+		if child.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("Expected %s to be a child of the service span", name)
+		}
+	}
+}
+
+func TestTracing_ClientInjectsTraceparent(t *testing.T) {
+	recorder := installRecorder(t)
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Write([]byte("cat"))
+	}))
+	defer server.Close()
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "parent")
+	c := client.NewCataasClient(server.URL, 5)
+	if _, err := c.GetRandomCat(ctx, client.CatOptions{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	span.End()
+
+	if traceparent == "" {
+		t.Fatal("Expected traceparent header on the upstream request")
+	}
+
+	var clientSpans int
+	for _, s := range recorder.Ended() {
+		if s.Parent().TraceID() == span.SpanContext().TraceID() && s.Name() != "parent" {
+			clientSpans++
+		}
+	}
+	if clientSpans == 0 {
+		t.Error("Expected an HTTP client span in the same trace")
+	}
+}
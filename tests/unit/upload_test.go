@@ -0,0 +1,177 @@
+package services_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/IavilaGw/cat-api/internal/handlers"
+	"github.com/IavilaGw/cat-api/internal/models"
+	"github.com/IavilaGw/cat-api/internal/services"
+)
+
+func encodeTestImage(t *testing.T, format string, width, height int) []byte {
+	t.Helper()
+	img := image.NewPaletted(image.Rect(0, 0, width, height), color.Palette{color.Black, color.White})
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("Failed to encode %s: %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+// newUploadRouter usa un repositorio en memoria que deduplica por SHA-256
+// como el real; saved guarda el content type con el que se llamo a Save.
+func newUploadRouter(limits services.UploadLimits, saved *[]string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	stored := map[string]*models.CatImage{}
+	mockRepo := &MockCatRepository{
+		SaveFunc: func(ctx context.Context, data []byte, contentType string, variant models.CatVariant) (*models.CatImage, bool, error) {
+			*saved = append(*saved, contentType)
+			sum := sha256.Sum256(data)
+			hash := hex.EncodeToString(sum[:])
+			if img, ok := stored[hash]; ok {
+				return img, false, nil
+			}
+			img := &models.CatImage{ID: uint(len(stored) + 1), ImageHash: hash, ContentType: contentType, Size: int64(len(data))}
+			stored[hash] = img
+			return img, true, nil
+		},
+	}
+	service := services.NewCatService(mockRepo, &MockCataasClient{}, discardLogger).WithUploadLimits(limits)
+	handler := handlers.NewCatHandler(service, discardLogger)
+
+	r := gin.New()
+	r.POST("/api/images", handler.UploadImage)
+	return r
+}
+
+var testUploadLimits = services.UploadLimits{MaxBytes: 64 << 10, MaxPixels: 10_000}
+
+func postRaw(r *gin.Engine, data []byte, contentType string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/images", bytes.NewReader(data))
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestUploadImage_RawBodyAndDedup(t *testing.T) {
+	var saved []string
+	r := newUploadRouter(testUploadLimits, &saved)
+	data := encodeTestImage(t, "png", 10, 10)
+
+	// El Content-Type enviado miente; se guarda el tipo detectado
+	w := postRaw(r, data, "image/gif")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if saved[0] != "image/png" {
+		t.Errorf("Expected sniffed image/png, got %q", saved[0])
+	}
+	if w.Header().Get("Location") == "" {
+		t.Error("Expected Location header for a new image")
+	}
+
+	var first models.CatImage
+	json.Unmarshal(w.Body.Bytes(), &first)
+
+	w = postRaw(r, data, "application/octet-stream")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for a duplicate, got %d", w.Code)
+	}
+	var dup models.CatImage
+	json.Unmarshal(w.Body.Bytes(), &dup)
+	if dup.ID != first.ID || dup.ID == 0 {
+		t.Errorf("Expected existing ID %d, got %d", first.ID, dup.ID)
+	}
+}
+
+func TestUploadImage_Multipart(t *testing.T) {
+	var saved []string
+	r := newUploadRouter(testUploadLimits, &saved)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("comment", "mi gato")
+	part, _ := form.CreateFormFile("image", "cat.gif")
+	part.Write(encodeTestImage(t, "gif", 20, 20))
+	form.Close()
+
+	w := postRaw(r, body.Bytes(), form.FormDataContentType())
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(saved) != 1 || saved[0] != "image/gif" {
+		t.Errorf("Expected a gif to be saved, got %v", saved)
+	}
+}
+
+func TestUploadImage_MultipartWithoutImageField(t *testing.T) {
+	var saved []string
+	r := newUploadRouter(testUploadLimits, &saved)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("comment", "sin imagen")
+	form.Close()
+
+	if w := postRaw(r, body.Bytes(), form.FormDataContentType()); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", w.Code)
+	}
+}
+
+func TestUploadImage_Rejections(t *testing.T) {
+	pngData := encodeTestImage(t, "png", 10, 10)
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"vacio", nil, http.StatusUnprocessableEntity},
+		{"texto", []byte("definitely not a cat"), http.StatusUnsupportedMediaType},
+		{"html", []byte("<html><body>cat</body></html>"), http.StatusUnsupportedMediaType},
+		{"png truncado", pngData[:len(pngData)/2], http.StatusUnprocessableEntity},
+		{"demasiados pixeles", encodeTestImage(t, "jpeg", 200, 200), http.StatusUnprocessableEntity},
+		{"demasiado grande", append(append([]byte{}, pngData...), make([]byte, 64<<10)...), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var saved []string
+			r := newUploadRouter(testUploadLimits, &saved)
+
+			w := postRaw(r, tt.data, "image/png")
+			if w.Code != tt.want {
+				t.Errorf("Expected %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+			if len(saved) != 0 {
+				t.Error("Expected nothing to be saved")
+			}
+		})
+	}
+}